@@ -0,0 +1,162 @@
+// Package arch describes the architectures susi can be built for
+package arch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"strings"
+
+	"github.com/webvariants/susi-dev/executor"
+)
+
+// Default is used when no architecture is selected, it is also the architecture of the build host
+const Default = "amd64"
+
+// Arch is a target architecture with its names in the different toolchains
+type Arch struct {
+	// Name is the name used by susi-dev and in file names
+	Name string
+	// Alpine is the apk architecture
+	Alpine string
+	// Debian is the dpkg architecture
+	Debian string
+	// ACI is the arch label of appc images
+	ACI string
+	// Platform is the OCI platform
+	Platform string
+	// Docker is the namespace of the official images on docker hub
+	Docker string
+	// Musl and GNU are the target triplets of the cross toolchains
+	Musl string
+	GNU  string
+	// Processor is the CMAKE_SYSTEM_PROCESSOR
+	Processor string
+	// Qemu is the name of the qemu-user emulator running programs of the architecture
+	Qemu   string
+	GOARCH string
+	GOARM  string
+}
+
+var archs = []*Arch{
+	{
+		Name:      "amd64",
+		Alpine:    "x86_64",
+		Debian:    "amd64",
+		ACI:       "amd64",
+		Platform:  "linux/amd64",
+		Docker:    "library",
+		Musl:      "x86_64-linux-musl",
+		GNU:       "x86_64-linux-gnu",
+		Processor: "x86_64",
+		Qemu:      "x86_64",
+		GOARCH:    "amd64",
+	},
+	{
+		Name:      "arm",
+		Alpine:    "armhf",
+		Debian:    "armhf",
+		ACI:       "armv7l",
+		Platform:  "linux/arm/v7",
+		Docker:    "arm32v7",
+		Musl:      "arm-linux-musleabihf",
+		GNU:       "arm-linux-gnueabihf",
+		Processor: "arm",
+		Qemu:      "arm",
+		GOARCH:    "arm",
+		GOARM:     "7",
+	},
+	{
+		Name:      "arm64",
+		Alpine:    "aarch64",
+		Debian:    "arm64",
+		ACI:       "aarch64",
+		Platform:  "linux/arm64",
+		Docker:    "arm64v8",
+		Musl:      "aarch64-linux-musl",
+		GNU:       "aarch64-linux-gnu",
+		Processor: "aarch64",
+		Qemu:      "aarch64",
+		GOARCH:    "arm64",
+	},
+}
+
+// Names lists the names accepted by Lookup
+func Names() []string {
+	names := make([]string, 0, len(archs))
+	for _, a := range archs {
+		names = append(names, a.Name)
+	}
+	return names
+}
+
+// Lookup returns the architecture with the given name, "" is the default architecture
+func Lookup(name string) (*Arch, error) {
+	if name == "" {
+		name = Default
+	}
+	for _, a := range archs {
+		if a.Name == name {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("no such architecture: %v (use one of %v)", name, strings.Join(Names(), ", "))
+}
+
+// Cross reports whether building for the architecture needs a cross toolchain
+func (a *Arch) Cross() bool {
+	return a.Name != Default
+}
+
+// binfmtDir lists the interpreters the kernel runs programs of other architectures with
+var binfmtDir = "/proc/sys/fs/binfmt_misc"
+
+// CheckEmulation fails with executor.ErrMissing if programs of the architecture cannot run on the build host,
+// which needs a qemu-user binfmt handler unless the host has the architecture
+func (a *Arch) CheckEmulation() error {
+	if a.GOARCH == runtime.GOARCH {
+		return nil
+	}
+	data, err := ioutil.ReadFile(binfmtDir + "/qemu-" + a.Qemu)
+	if err == nil && strings.HasPrefix(string(data), "enabled") {
+		return nil
+	}
+	return fmt.Errorf("%w: %v programs cannot run on this %v host, register the binfmt handler qemu-%v by installing qemu-user-static and binfmt-support",
+		executor.ErrMissing, a.Name, runtime.GOARCH, a.Qemu)
+}
+
+// Suffix is appended to image files and tags built for the architecture
+func (a *Arch) Suffix() string {
+	return "-linux-" + a.Name
+}
+
+// Toolchain returns a cmake toolchain file for compilers with the given triplet.
+// Libraries are taken from sysroot, an empty sysroot uses the multiarch directories of the host.
+func (a *Arch) Toolchain(triplet, sysroot string) string {
+	toolchain := fmt.Sprintf(`set(CMAKE_SYSTEM_NAME Linux)
+set(CMAKE_SYSTEM_PROCESSOR %v)
+set(CMAKE_C_COMPILER %v-gcc)
+set(CMAKE_CXX_COMPILER %v-g++)
+`, a.Processor, triplet, triplet)
+	mode := "ONLY"
+	if sysroot != "" {
+		toolchain += fmt.Sprintf("set(CMAKE_SYSROOT %v)\nset(CMAKE_FIND_ROOT_PATH %v)\n", sysroot, sysroot)
+	} else {
+		// multiarch headers and libraries live below /usr/include and /usr/lib/<triplet>
+		toolchain += fmt.Sprintf("set(CMAKE_LIBRARY_ARCHITECTURE %v)\n", triplet)
+		mode = "BOTH"
+	}
+	return toolchain + fmt.Sprintf(`set(CMAKE_FIND_ROOT_PATH_MODE_PROGRAM NEVER)
+set(CMAKE_FIND_ROOT_PATH_MODE_LIBRARY %v)
+set(CMAKE_FIND_ROOT_PATH_MODE_INCLUDE %v)
+`, mode, mode)
+}
+
+// GoEnv returns the environment for cross compiling go programs
+func (a *Arch) GoEnv() string {
+	env := fmt.Sprintf("CGO_ENABLED=0 GOOS=linux GOARCH=%v", a.GOARCH)
+	if a.GOARM != "" {
+		env += " GOARM=" + a.GOARM
+	}
+	return env
+}
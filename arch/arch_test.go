@@ -0,0 +1,70 @@
+package arch
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/webvariants/susi-dev/executor"
+)
+
+func TestCheckEmulation(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		handler string
+		ok      bool
+	}{
+		{"registered", "enabled\ninterpreter /usr/bin/qemu-%v-static\n", true},
+		{"disabled", "disabled\ninterpreter /usr/bin/qemu-%v-static\n", false},
+		{"missing", "", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			previous := binfmtDir
+			binfmtDir = dir
+			defer func() { binfmtDir = previous }()
+			for _, name := range Names() {
+				a, err := Lookup(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if test.handler != "" {
+					if err := ioutil.WriteFile(filepath.Join(dir, "qemu-"+a.Qemu), []byte(test.handler), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				err = a.CheckEmulation()
+				switch {
+				case a.GOARCH == runtime.GOARCH || test.ok:
+					if err != nil {
+						t.Errorf("%v: %v", name, err)
+					}
+				case !errors.Is(err, executor.ErrMissing):
+					t.Errorf("%v on %v without a registered handler: %v, want a missing dependency", name, runtime.GOARCH, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	for _, test := range []struct {
+		name, want string
+	}{
+		{"", Default},
+		{"amd64", "amd64"},
+		{"arm", "arm"},
+		{"arm64", "arm64"},
+		{"mips", ""},
+	} {
+		a, err := Lookup(test.name)
+		switch {
+		case test.want == "" && err == nil:
+			t.Errorf("Lookup(%q) found %v", test.name, a.Name)
+		case test.want != "" && (err != nil || a.Name != test.want):
+			t.Errorf("Lookup(%q) = %v, %v, want %v", test.name, a, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,965 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/webvariants/susi-dev/arch"
+	"github.com/webvariants/susi-dev/components"
+	"github.com/webvariants/susi-dev/container"
+	"github.com/webvariants/susi-dev/deploy"
+	"github.com/webvariants/susi-dev/pki"
+	"github.com/webvariants/susi-dev/project"
+	"github.com/webvariants/susi-dev/setup"
+	"github.com/webvariants/susi-dev/sign"
+	"github.com/webvariants/susi-dev/source"
+)
+
+// command is a node of the command tree, it either has subcommands or runs
+type command struct {
+	name   string
+	params []param
+	usage  string
+	// flags registers the flags of the command
+	flags func(f *flag.FlagSet)
+	// values completes flag values of this command, before the ones in flagValues
+	values      map[string]func() []string
+	subcommands []*command
+	// hidden commands are neither listed in the help nor completed
+	hidden bool
+	// run gets the validated positional arguments and the parsed flags
+	run func(args []string, f *flag.FlagSet) error
+}
+
+// param is a positional argument of a command
+type param struct {
+	name     string
+	optional bool
+	// rest takes all remaining arguments as they are, like the journalctl options of logs
+	rest bool
+	// files completes file names
+	files bool
+	// complete returns the candidates of the argument, args are the arguments before it
+	complete func(args []string) []string
+	// validate checks the argument, args are the arguments before it
+	validate func(args []string, value string) error
+}
+
+// systems are the operating systems susi is built for
+var systems = []string{"alpine", "debian-stable", "debian-testing", "native"}
+
+// validNodeID keeps node ids usable as directory and host names
+var validNodeID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// nodeParam is a node of the project
+func nodeParam(name string) param {
+	return param{name: name, complete: completeNodes, validate: func(_ []string, id string) error {
+		myProject := loadProject()
+		if _, ok := myProject.Nodes[id]; !ok {
+			return usagef("no such node: %v (the project has %v)", id, orNone(myProject.IDs()))
+		}
+		return nil
+	}}
+}
+
+// newNodeParam is the id of a node which does not exist yet
+func newNodeParam(name string) param {
+	return param{name: name, validate: func(_ []string, id string) error {
+		if !validNodeID.MatchString(id) {
+			return usagef("invalid node id: %v, use letters, digits, '.', '_' and '-'", id)
+		}
+		if _, ok := loadProject().Nodes[id]; ok {
+			return usagef("%v exists already", id)
+		}
+		return nil
+	}}
+}
+
+// componentParam is a known component
+func componentParam(name string) param {
+	return param{name: name, complete: completeComponents, validate: func(_ []string, component string) error {
+		loadProject()
+		if _, err := components.Lookup(component); err != nil {
+			return usagef("%v (see susi-dev components)", err)
+		}
+		return nil
+	}}
+}
+
+// nodeComponentParam is a component of the node given as first argument
+func nodeComponentParam(name string) param {
+	return param{
+		name: name,
+		complete: func(args []string) []string {
+			if myProject, err := project.Load(); err == nil && len(args) > 0 {
+				if node, ok := myProject.Nodes[args[0]]; ok {
+					return node.Components
+				}
+			}
+			return nil
+		},
+		validate: func(args []string, component string) error {
+			node := loadNode(loadProject(), args[0])
+			if !contains(node.Components, component) {
+				return usagef("%v is not a component of %v (it has %v)", component, node.ID, orNone(node.Components))
+			}
+			return nil
+		},
+	}
+}
+
+// parameterParam is a parameter of the component before it, other config paths are accepted too
+func parameterParam(name string) param {
+	return param{name: name, complete: func(args []string) []string {
+		if len(args) < 2 {
+			return nil
+		}
+		completeComponents(nil)
+		definition, err := components.Lookup(args[1])
+		if err != nil {
+			return nil
+		}
+		var names []string
+		for _, parameter := range definition.Parameters {
+			names = append(names, parameter.Name)
+		}
+		return names
+	}}
+}
+
+// choiceParam is one of a fixed list of values
+func choiceParam(name string, values []string) param {
+	return param{name: name, complete: func([]string) []string { return values }, validate: func(_ []string, value string) error {
+		if !contains(values, value) {
+			return usagef("%v is not one of %v", value, strings.Join(values, ", "))
+		}
+		return nil
+	}}
+}
+
+// optional makes a parameter optional
+func optional(p param) param {
+	p.optional = true
+	return p
+}
+
+func orNone(list []string) string {
+	if len(list) == 0 {
+		return "none"
+	}
+	return strings.Join(list, ", ")
+}
+
+// signFlags registers the flags selecting how images are signed
+func signFlags(f *flag.FlagSet) {
+	gpgPass = f.String("gpgpass", "", "removed, see --passphrase-file")
+	f.StringVar(&signConfig.Signer, "signer", "gpg", "how images are signed ("+strings.Join(sign.Signers, ", ")+")")
+	f.StringVar(&signConfig.KeyID, "sign-key", "", "id of the signing key, defaults to the default key")
+	f.StringVar(&signConfig.PassphraseFile, "passphrase-file", "", "file with the passphrase of the signing key, defaults to $"+sign.PassphraseEnv+" or gpg-agent")
+	f.StringVar(&signConfig.SecretKey, "secret-key", "", "armored secret key file for --signer openpgp")
+	noSign = f.Bool("no-sign", false, "do not sign images")
+}
+
+// nodeIDs returns the node given as optional argument or all nodes of the project
+func nodeIDs(args []string) []string {
+	if len(args) > 0 {
+		return args
+	}
+	return loadProject().IDs()
+}
+
+// root is the command tree of susi-dev
+var root *command
+
+func init() {
+	root = &command{name: "susi-dev", subcommands: []*command{
+		{name: "setup", usage: "install container tools", run: func(args []string, f *flag.FlagSet) error {
+			return setup.InstallDependencies()
+		}},
+		{
+			name:   "create",
+			params: []param{newNodeParam("$node")},
+			usage:  "bootstrap a new node",
+			flags: func(f *flag.FlagSet) {
+				fqdn = f.String("fqdn", "", "address of the node, defaults to $node")
+				staticIP = f.String("ip", "", "pin the IP of the node instead of taking the next free one of the subnet")
+			},
+			run: func(args []string, f *flag.FlagSet) error {
+				return create(loadProject(), args[0], *fqdn, *staticIP)
+			},
+		},
+		{name: "node", usage: "delete, rename or clone nodes", subcommands: []*command{
+			{
+				name:   "delete",
+				params: []param{nodeParam("$node")},
+				usage:  "stop a node, revoke its certificates in the pki of its peers, disconnect the nodes connected to it and remove its directory and IP",
+				run: func(args []string, f *flag.FlagSet) error {
+					return deleteNode(loadProject(), args[0])
+				},
+			},
+			{
+				name:   "rename",
+				params: []param{nodeParam("$node"), newNodeParam("$new")},
+				usage:  "rename a node, its certificates, foreignKeys and cluster configs and the ones of connected nodes are updated",
+				run: func(args []string, f *flag.FlagSet) error {
+					return renameNode(loadProject(), args[0], args[1])
+				},
+			},
+			{
+				name:   "clone",
+				params: []param{nodeParam("$node"), newNodeParam("$new")},
+				usage:  "create a node with a fresh pki and the components, configs, assets and connections of another node",
+				flags: func(f *flag.FlagSet) {
+					fqdn = f.String("fqdn", "", "address of the new node, defaults to $new")
+					staticIP = f.String("ip", "", "pin the IP of the new node instead of taking the next free one of the subnet")
+				},
+				run: func(args []string, f *flag.FlagSet) error {
+					return cloneNode(loadProject(), args[0], args[1])
+				},
+			},
+		}},
+		{name: "fleet", usage: "create many similar nodes", subcommands: []*command{
+			{
+				name:   "create",
+				params: []param{{name: "$blueprint", files: true}},
+				usage:  "create a node for --count or for every row of the --from inventory with the components, configs, assets and connection of the blueprint",
+				flags: func(f *flag.FlagSet) {
+					fleetCount = f.Int("count", 0, "number of nodes, they are named by the name template of the blueprint")
+					fleetInventory = f.String("from", "", "csv file with a header row, every row is a node and its columns are variables (name, fqdn and ip are used for the node)")
+				},
+				run: func(args []string, f *flag.FlagSet) error {
+					return createFleet(loadProject(), args[0], *fleetCount, *fleetInventory)
+				},
+			},
+		}},
+		{
+			name:   "add",
+			params: []param{nodeParam("$node"), componentParam("$component")},
+			usage:  "setup a component on the given node",
+			flags: func(f *flag.FlagSet) {
+				connectTo = f.String("connect-to", "", "node the component connects to")
+				addSettings = nil
+				f.Var(&addSettings, "set", "set a parameter (see susi-dev components $component) or a config path like ports[0].port=/dev/ttyACM0, repeat it for more")
+			},
+			run: func(args []string, f *flag.FlagSet) error {
+				myProject := loadProject()
+				return addComponent(myProject, loadNode(myProject, args[0]), args[1], *connectTo, addSettings)
+			},
+		},
+		{
+			name:   "connect",
+			params: []param{nodeParam("$node"), nodeParam("$peer")},
+			usage:  "connect the susi-cluster of a node to another node, repeat it to add more peers",
+			flags: func(f *flag.FlagSet) {
+				for name, list := range topicFlags {
+					f.String(name, "", "comma separated topics for "+list)
+				}
+			},
+			run: func(args []string, f *flag.FlagSet) error {
+				topics := components.Topics{}
+				f.Visit(func(flag *flag.Flag) {
+					topics[topicFlags[flag.Name]] = strings.FieldsFunc(flag.Value.String(), func(r rune) bool { return r == ',' })
+				})
+				return connect(loadProject(), args[0], args[1], topics)
+			},
+		},
+		{
+			name:   "disconnect",
+			params: []param{nodeParam("$node"), nodeParam("$peer")},
+			usage:  "remove the connection to another node and revoke its certificate",
+			run: func(args []string, f *flag.FlagSet) error {
+				myProject := loadProject()
+				node := loadNode(myProject, args[0])
+				if planned("disconnect %v from %v: revoke its certificate in the pki of %v, remove it from %v/foreignKeys and the cluster config", args[0], args[1], args[1], args[0]) {
+					return nil
+				}
+				if err := components.Disconnect(args[0], args[1]); err != nil {
+					return err
+				}
+				node.RemoveConnection(args[1])
+				saveProject(myProject)
+				return nil
+			},
+		},
+		{
+			name:   "remove",
+			params: []param{nodeParam("$node"), nodeComponentParam("$component")},
+			usage:  "remove a component from the given node, it is removed from targets on the next deploy",
+			run: func(args []string, f *flag.FlagSet) error {
+				return remove(loadProject(), args[0], args[1])
+			},
+		},
+		{
+			name:   "components",
+			params: []param{optional(componentParam("$component"))},
+			usage:  "list the available components or the parameters of one",
+			run: func(args []string, f *flag.FlagSet) error {
+				loadProject()
+				if len(args) > 0 {
+					return printParameters("", args[0])
+				}
+				for _, name := range components.Names() {
+					fmt.Println(name)
+				}
+				return nil
+			},
+		},
+		{name: "config", usage: "show and change the configs of the components of a node", subcommands: []*command{
+			{
+				name:   "get",
+				params: []param{nodeParam("$node"), nodeComponentParam("$component"), optional(parameterParam("$name"))},
+				usage:  "print a parameter or a config path, without $name all parameters",
+				run: func(args []string, f *flag.FlagSet) error {
+					if len(args) < 3 {
+						return printParameters(args[0], args[1])
+					}
+					value, err := components.Get(args[0], args[1], args[2])
+					if err != nil {
+						return err
+					}
+					fmt.Println(formatValue(value))
+					return nil
+				},
+			},
+			{
+				name:   "set",
+				params: []param{nodeParam("$node"), nodeComponentParam("$component"), parameterParam("$name"), {name: "$value"}},
+				usage:  "change a parameter or a config path, the value is converted to the type of the parameter",
+				run: func(args []string, f *flag.FlagSet) error {
+					if planned("set %v of %v on %v to %v", args[2], args[1], args[0], args[3]) {
+						return nil
+					}
+					return components.Set(args[0], args[1], components.Setting{Name: args[2], Value: args[3]})
+				},
+			},
+		}},
+		{
+			name:   "nodes",
+			usage:  "list the nodes with ip, fqdn, architecture, components and pod",
+			flags:  outputFlag,
+			values: outputValues,
+			run: func(args []string, f *flag.FlagSet) error {
+				return listNodes(loadProject())
+			},
+		},
+		{
+			name:   "list",
+			params: []param{nodeParam("$node")},
+			usage:  "list the components of a node",
+			flags:  outputFlag,
+			values: outputValues,
+			run: func(args []string, f *flag.FlagSet) error {
+				return listComponents(loadNode(loadProject(), args[0]))
+			},
+		},
+		{
+			name:   "bundle",
+			params: []param{nodeParam("$node")},
+			usage:  "write an archive with binaries, configs, keys, assets, units and an install.sh for offline installation",
+			flags: func(f *flag.FlagSet) {
+				bundleOS = f.String("os", "alpine", "which susi build to take the binaries from ("+strings.Join(systems, ", ")+")")
+				bundleOut = f.String("output", "", "bundle file, defaults to susi-$node-linux-$arch.tar.gz")
+			},
+			run: func(args []string, f *flag.FlagSet) error {
+				return bundle(loadNode(loadProject(), args[0]))
+			},
+		},
+		{
+			name:   "package",
+			params: []param{nodeParam("$node")},
+			usage:  "build a debian package of the node which depends on susi",
+			flags: func(f *flag.FlagSet) {
+				packageFormat = f.String("format", "deb", "package format, only deb is supported")
+				packageVersion = f.String("version", "", "package version, defaults to the current time")
+				packageMaintainer = f.String("maintainer", "susi-dev <root@localhost>", "maintainer of the package")
+				packageDepends = f.String("depends", "susi", "dependencies of the package")
+			},
+			run: func(args []string, f *flag.FlagSet) error {
+				return packageNode(loadNode(loadProject(), args[0]))
+			},
+		},
+		{
+			name: "deploy",
+			params: []param{nodeParam("$node"), {name: "$target", complete: func(args []string) []string {
+				if myProject, err := project.Load(); err == nil && len(args) > 0 {
+					if node, ok := myProject.Nodes[args[0]]; ok {
+						return node.Targets
+					}
+				}
+				return nil
+			}}},
+			usage: "deploy a node to a target via ssh, rolls back if restarted units fail",
+			flags: func(f *flag.FlagSet) {
+				deployPlan = f.Bool("plan", false, "only list the changes, exits with 2 if there are any")
+			},
+			run: func(args []string, f *flag.FlagSet) error {
+				return deployNode(loadProject(), args[0], args[1])
+			},
+		},
+		{name: "source", usage: "work with the susi source", subcommands: []*command{
+			{name: "clone", usage: "clone the source of susi", run: func(args []string, f *flag.FlagSet) error {
+				return cloneSource()
+			}},
+			{
+				name:   "checkout",
+				params: []param{{name: "$branch"}},
+				usage:  "checkout a specific branch",
+				run: func(args []string, f *flag.FlagSet) error {
+					return source.Checkout(args[0])
+				},
+			},
+			{
+				name:  "build",
+				usage: "build it for one of " + strings.Join(systems, ", ") + " and one of " + strings.Join(arch.Names(), ", "),
+				flags: func(f *flag.FlagSet) {
+					targetOS = f.String("os", "alpine", "for which OS ("+strings.Join(systems, ", ")+")")
+					targetArch = f.String("arch", "", "for which architecture ("+strings.Join(arch.Names(), ", ")+"), defaults to amd64, native builds default to the host")
+					signFlags(f)
+				},
+				run: func(args []string, f *flag.FlagSet) error {
+					return buildSource()
+				},
+			},
+		}},
+		{
+			name:   "build",
+			params: []param{optional(nodeParam("$node"))},
+			usage:  "build containers of a node or all nodes, --arch selects the architecture of the node",
+			flags: func(f *flag.FlagSet) {
+				targetOS = f.String("os", "alpine", "for which OS, images are built on alpine")
+				targetArch = f.String("arch", "", "for which architecture ("+strings.Join(arch.Names(), ", ")+"), defaults to the architecture of the node")
+				signFlags(f)
+			},
+			run: func(args []string, f *flag.FlagSet) error {
+				myProject := loadProject()
+				if err := validate(myProject, nodeIDs(args)...); err != nil {
+					return err
+				}
+				for _, id := range nodeIDs(args) {
+					if err := build(myProject, id); err != nil {
+						return err
+					}
+				}
+				saveProject(myProject)
+				return nil
+			},
+		},
+		{
+			name:   "validate",
+			params: []param{optional(nodeParam("$node"))},
+			usage:  "check the configs of a node or all nodes and the keys and assets they refer to",
+			run: func(args []string, f *flag.FlagSet) error {
+				return validate(loadProject(), nodeIDs(args)...)
+			},
+		},
+		{
+			name:   "start",
+			params: []param{optional(nodeParam("$node"))},
+			usage:  "runs the containers, images have to be signed by a trusted key unless --insecure is given",
+			flags: func(f *flag.FlagSet) {
+				startInsecure = f.Bool("insecure", false, "start images which are not signed by a trusted key")
+			},
+			run: forEachNode(start),
+		},
+		{name: "trust", usage: "manage the keys images have to be signed with", subcommands: []*command{
+			{
+				name:   "add",
+				params: []param{{name: "$file", files: true}},
+				usage:  "trust the public keys in $file for signed images",
+				run: func(args []string, f *flag.FlagSet) error {
+					if planned("trust the public keys in %v, copy them into %v", args[0], sign.TrustDir) {
+						return nil
+					}
+					fingerprints, err := sign.Trust(sign.TrustDir, args[0])
+					if err != nil {
+						return err
+					}
+					for _, fingerprint := range fingerprints {
+						fmt.Printf("trusting %v\n", fingerprint)
+					}
+					return nil
+				},
+			},
+			{name: "list", usage: "list the trusted keys", run: func(args []string, f *flag.FlagSet) error {
+				keys, err := sign.Keys(sign.TrustDir)
+				if err != nil {
+					return err
+				}
+				for _, key := range keys {
+					fmt.Printf("%v %v\n", key.Fingerprint, strings.Join(key.Identities, ", "))
+				}
+				return nil
+			}},
+		}},
+		{name: "stop", params: []param{optional(nodeParam("$node"))}, usage: "stops the containers", run: forEachNode(stop)},
+		{
+			name:   "status",
+			params: []param{optional(nodeParam("$node"))},
+			usage:  "shows the state of the containers",
+			flags:  outputFlag,
+			values: outputValues,
+			run: func(args []string, f *flag.FlagSet) error {
+				asJSON, err := jsonOutput()
+				if err != nil {
+					return err
+				}
+				if asJSON {
+					return statusReport(loadProject(), nodeIDs(args), len(args) > 0)
+				}
+				return forEachNode(status)(args, f)
+			},
+		},
+		{
+			name:   "logs",
+			params: []param{nodeParam("$node"), {name: "journalctl options", rest: true}},
+			usage:  "show the container logs",
+			run: func(args []string, f *flag.FlagSet) error {
+				myProject := loadProject()
+				node := loadNode(myProject, args[0])
+				return nodeRuntime(myProject, node).Logs(node, args[1:])
+			},
+		},
+		{
+			name:   "enter",
+			params: []param{nodeParam("$node"), optional(nodeComponentParam("$app"))},
+			usage:  "open a shell in an app of a running node (default susi-core)",
+			run: func(args []string, f *flag.FlagSet) error {
+				app := "susi-core"
+				if len(args) > 1 {
+					app = args[1]
+				}
+				myProject := loadProject()
+				node := loadNode(myProject, args[0])
+				return nodeRuntime(myProject, node).Enter(node, app)
+			},
+		},
+		{
+			name:   "runtime",
+			params: []param{optional(choiceParam("$runtime", container.Runtimes))},
+			usage:  "show or select the container runtime of the project (" + strings.Join(container.Runtimes, ", ") + ")",
+			run: func(args []string, f *flag.FlagSet) error {
+				myProject := loadProject()
+				if len(args) == 0 {
+					fmt.Println(projectRuntime(myProject).Name())
+					return nil
+				}
+				myProject.Runtime = args[0]
+				saveProject(myProject)
+				return nil
+			},
+		},
+		{
+			name:   "subnet",
+			params: []param{optional(param{name: "$subnet"})},
+			usage:  "show or select the subnet the IPs of the nodes are taken from, nodes outside of it get new IPs",
+			run: func(args []string, f *flag.FlagSet) error {
+				myProject := loadProject()
+				if len(args) == 0 {
+					fmt.Println(myProject.SubnetOrDefault())
+					return nil
+				}
+				moved, err := myProject.SetSubnet(args[0])
+				if err != nil {
+					return usagef("%v", err)
+				}
+				for _, id := range moved {
+					fmt.Printf("%v moved to %v, rebuild and restart it\n", id, myProject.Nodes[id].IP)
+				}
+				saveProject(myProject)
+				return nil
+			},
+		},
+		{name: "dns", usage: "resolve the nodes at runtime instead of with the /etc/hosts of the images", subcommands: []*command{
+			{name: "enable", usage: "let the pods use the resolver of susi-dev dns serve, rebuild and restart the nodes afterwards", run: func(args []string, f *flag.FlagSet) error {
+				myProject := loadProject()
+				myProject.DNS = true
+				saveProject(myProject)
+				return nil
+			}},
+			{name: "disable", usage: "list the nodes in the /etc/hosts of the images again, rebuild and restart the nodes afterwards", run: func(args []string, f *flag.FlagSet) error {
+				myProject := loadProject()
+				myProject.DNS = false
+				saveProject(myProject)
+				return nil
+			}},
+			{
+				name:  "serve",
+				usage: "answer the queries of the pods for node ids and FQDNs, other names are forwarded",
+				flags: func(f *flag.FlagSet) {
+					dnsListen = f.String("listen", "", "address to listen on, defaults to port 53 of the gateway of the subnet")
+					dnsUpstream = f.String("upstream", "", "resolver for other names, defaults to the first nameserver of /etc/resolv.conf, none disables forwarding")
+				},
+				run: func(args []string, f *flag.FlagSet) error {
+					return serveDNS(loadProject())
+				},
+			},
+		}},
+		{name: "pki", usage: "manage public key infrastructures", subcommands: []*command{
+			{
+				name:   "create",
+				params: []param{{name: "$folder", files: true}},
+				usage:  "create a new public key infrastructure",
+				run: func(args []string, f *flag.FlagSet) error {
+					if planned("create a pki in %v", args[0]) {
+						return nil
+					}
+					return pki.Init(args[0])
+				},
+			},
+			{
+				name:   "add",
+				params: []param{{name: "$folder", files: true}, {name: "$client"}},
+				usage:  "create and sign a new client certificate",
+				run: func(args []string, f *flag.FlagSet) error {
+					if planned("issue a certificate for %v in the pki %v", args[1], args[0]) {
+						return nil
+					}
+					return pki.CreateCertificate(args[0], args[1])
+				},
+			},
+			{
+				name:   "revoke",
+				params: []param{{name: "$folder", files: true}, {name: "$client"}},
+				usage:  "revoke a client certificate and update the crl",
+				run: func(args []string, f *flag.FlagSet) error {
+					if planned("revoke the certificate of %v in the pki %v and update its crl", args[1], args[0]) {
+						return nil
+					}
+					return pki.Revoke(args[0], args[1])
+				},
+			},
+			{
+				name:   "crl",
+				params: []param{{name: "$folder", files: true}},
+				usage:  "regenerate the certificate revocation list",
+				run: func(args []string, f *flag.FlagSet) error {
+					if planned("regenerate the crl of the pki %v", args[0]) {
+						return nil
+					}
+					return pki.GenerateCRL(args[0])
+				},
+			},
+			{
+				name:   "status",
+				usage:  "list all certificates of all nodes with their expiry",
+				flags:  outputFlag,
+				values: outputValues,
+				run: func(args []string, f *flag.FlagSet) error {
+					return pkiStatus(loadProject())
+				},
+			},
+			{
+				name:  "renew",
+				usage: "reissue certificates expiring within --days and refresh foreignKeys",
+				flags: func(f *flag.FlagSet) {
+					renewDays = f.Int("days", 30, "renew certificates expiring within this many days")
+				},
+				run: func(args []string, f *flag.FlagSet) error {
+					return pkiRenew(loadProject(), *renewDays)
+				},
+			},
+		}},
+		{
+			name:   "completion",
+			params: []param{choiceParam("$shell", shells)},
+			usage:  "print the completion script for " + strings.Join(shells, ", "),
+			run: func(args []string, f *flag.FlagSet) error {
+				_, err := io.WriteString(os.Stdout, completionScripts[args[0]])
+				return err
+			},
+		},
+		{
+			name:   "help",
+			params: []param{{name: "$command", rest: true}},
+			usage:  "show the usage and flags of a command",
+			run: func(args []string, f *flag.FlagSet) error {
+				c, path := root, root.name
+				for _, name := range args {
+					if c = c.find(name); c == nil {
+						return usagef("no such command: %v %v", path, name)
+					}
+					path += " " + name
+				}
+				c.printUsage(os.Stdout, path)
+				return nil
+			},
+		},
+		{name: "__complete", hidden: true, params: []param{{name: "$words", rest: true}}, run: func(args []string, f *flag.FlagSet) error {
+			for _, candidate := range root.complete(args) {
+				fmt.Println(candidate)
+			}
+			return nil
+		}},
+	}}
+}
+
+// forEachNode runs action for the node given as argument or all nodes
+func forEachNode(action func(myProject *project.Project, nodeID string) error) func(args []string, f *flag.FlagSet) error {
+	return func(args []string, f *flag.FlagSet) error {
+		myProject := loadProject()
+		for _, id := range nodeIDs(args) {
+			if err := action(myProject, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// find returns the subcommand with the given name
+func (c *command) find(name string) *command {
+	for _, sub := range c.subcommands {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// synopsis is the command with its arguments, optional ones are in parentheses
+func (c *command) synopsis() string {
+	line := c.name
+	for _, p := range c.params {
+		if p.optional || p.rest {
+			line += " (" + p.name + ")"
+		} else {
+			line += " " + p.name
+		}
+	}
+	return line
+}
+
+// flagSet returns the flags of the command, parse errors are reported by execute
+func (c *command) flagSet(path string) *flag.FlagSet {
+	f := flag.NewFlagSet(path, flag.ContinueOnError)
+	f.SetOutput(ioutil.Discard)
+	if c.flags != nil {
+		c.flags(f)
+	}
+	return f
+}
+
+// printTree lists the subcommands below c
+func (c *command) printTree(w io.Writer, indent string) {
+	for _, sub := range c.subcommands {
+		if sub.hidden {
+			continue
+		}
+		line := indent + sub.synopsis()
+		if sub.usage != "" && len(sub.subcommands) == 0 {
+			line += " -> " + sub.usage
+		}
+		fmt.Fprintln(w, line)
+		sub.printTree(w, indent+"  ")
+	}
+}
+
+// printUsage shows how to call the command, path is the command line up to c
+func (c *command) printUsage(w io.Writer, path string) {
+	if c == root {
+		fmt.Fprintf(w, "usage: %v (--dry-run) $command\n", path)
+		fmt.Fprintln(w, "  --dry-run -> print the scripts and commands instead of running them, the project file is not changed")
+		c.printTree(w, "  ")
+		fmt.Fprintln(w, "exit codes: 0 success, 1 failed step, 2 deploy --plan found changes, 64 usage error, 69 missing dependency")
+		fmt.Fprintln(w, "run susi-dev help $command to see the flags of a command")
+		return
+	}
+	if len(c.subcommands) > 0 {
+		fmt.Fprintf(w, "usage: %v $command\n%v\n", path, c.usage)
+		c.printTree(w, "  ")
+		return
+	}
+	f := c.flagSet(path)
+	synopsis := strings.TrimPrefix(c.synopsis(), c.name)
+	hasFlags := false
+	f.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		synopsis += " (flags)"
+	}
+	fmt.Fprintf(w, "usage: %v%v\n%v\n", path, synopsis, c.usage)
+	if hasFlags {
+		fmt.Fprintln(w, "flags:")
+		f.SetOutput(w)
+		f.PrintDefaults()
+	}
+}
+
+// execute runs the command or one of its subcommands, path is the command line up to c
+func (c *command) execute(path string, args []string) error {
+	if len(c.subcommands) > 0 {
+		if len(args) == 0 {
+			return usagef("%v: missing command, one of %v", path, strings.Join(c.names(), ", "))
+		}
+		if args[0] == "--help" || args[0] == "-h" {
+			c.printUsage(os.Stdout, path)
+			return nil
+		}
+		sub := c.find(args[0])
+		if sub == nil {
+			return usagef("no such command: %v %v", path, args[0])
+		}
+		return sub.execute(path+" "+sub.name, args[1:])
+	}
+	f := c.flagSet(path)
+	args, err := c.parse(f, args)
+	if err == flag.ErrHelp {
+		c.printUsage(os.Stdout, path)
+		return nil
+	}
+	if err != nil {
+		return usagef("%v: %v", path, err)
+	}
+	if err := c.check(path, args); err != nil {
+		return err
+	}
+	return c.run(args, f)
+}
+
+// names returns the visible subcommands
+func (c *command) names() []string {
+	var names []string
+	for _, sub := range c.subcommands {
+		if !sub.hidden {
+			names = append(names, sub.name)
+		}
+	}
+	return names
+}
+
+// parse parses flags between the positional arguments, arguments for a rest parameter are kept as they are
+func (c *command) parse(f *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if n := len(positional); n < len(c.params) && c.params[n].rest {
+			return append(positional, args...), nil
+		}
+		if err := f.Parse(args); err != nil {
+			return nil, err
+		}
+		args = f.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// check validates the number and values of the positional arguments
+func (c *command) check(path string, args []string) error {
+	rest := len(c.params) > 0 && c.params[len(c.params)-1].rest
+	for i, p := range c.params {
+		if i >= len(args) {
+			if !p.optional && !p.rest {
+				return usagef("%v: missing %v", path, p.name)
+			}
+			break
+		}
+		if p.rest {
+			break
+		}
+		if p.validate != nil {
+			if err := p.validate(args[:i], args[i]); err != nil {
+				return err
+			}
+		}
+	}
+	if len(args) > len(c.params) && !rest {
+		return usagef("%v: unexpected arguments %v", path, strings.Join(args[len(c.params):], " "))
+	}
+	return nil
+}
+
+// buildSource builds susi for the OS and architecture given by the flags of source build
+func buildSource() error {
+	a := lookupArch(*targetArch)
+	var run func() error
+	switch *targetOS {
+	case "alpine":
+		run = func() error { return source.Build(a, newSigner()) }
+	case "debian-stable":
+		run = func() error { return source.Package("stable", a, newSigner()) }
+	case "debian-testing":
+		run = func() error { return source.Package("testing", a, newSigner()) }
+	case "native":
+		if *targetArch == "" {
+			a = lookupArch(runtime.GOARCH)
+		}
+		run = func() error { return source.BuildNative(a) }
+	default:
+		return usagef("no such target os: %v, use one of %v", *targetOS, strings.Join(systems, ", "))
+	}
+	if err := cloneSource(); err != nil {
+		return err
+	}
+	return run()
+}
+
+// cloneSource clones susi unless it has been cloned already
+func cloneSource() error {
+	if _, err := os.Stat(".susi-src"); err == nil {
+		return nil
+	}
+	return source.Clone()
+}
+
+// deployNode deploys a node or shows the plan of the deploy with --plan
+func deployNode(myProject *project.Project, nodeID, target string) error {
+	node := loadNode(myProject, nodeID)
+	if *deployPlan {
+		changes, err := deploy.Plan(nodeID, target, node.Removed)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		if len(changes) > 0 {
+			os.Exit(exitDrift)
+		}
+		return nil
+	}
+	if err := validate(myProject, nodeID); err != nil {
+		return err
+	}
+	if err := deploy.Raw(nodeID, target, node.Removed); err != nil {
+		return err
+	}
+	node.AddTarget(target)
+	saveProject(myProject)
+	return nil
+}
+
+// remove removes a component from a node, the connections of the node go away with the last component using them
+func remove(myProject *project.Project, nodeID, component string) error {
+	node := loadNode(myProject, nodeID)
+	if planned("remove %v from %v: revoke its certificate, delete its config and unit file and disconnect %v if it is the last component using the connections",
+		component, nodeID, nodeID) {
+		return nil
+	}
+	if err := components.Remove(nodeID, component); err != nil {
+		return err
+	}
+	node.RemoveComponent(component)
+	stillConnected := false
+	for _, c := range node.Components {
+		stillConnected = stillConnected || components.UsesConnections(c)
+	}
+	if components.UsesConnections(component) && !stillConnected {
+		peers := append([]string(nil), node.Connections...)
+		for _, peer := range peers {
+			if err := components.Disconnect(nodeID, peer); err != nil {
+				return err
+			}
+			node.RemoveConnection(peer)
+		}
+	}
+	saveProject(myProject)
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/webvariants/susi-dev/arch"
+	"github.com/webvariants/susi-dev/components"
+	"github.com/webvariants/susi-dev/project"
+	"github.com/webvariants/susi-dev/sign"
+)
+
+// completeFiles is answered by __complete when the shell should complete file names
+const completeFiles = ":files"
+
+// shells are the shells completion scripts are written for
+var shells = []string{"bash", "zsh", "fish"}
+
+// completionScripts ask susi-dev __complete for the candidates of the word under the cursor
+var completionScripts = map[string]string{
+	"bash": `# bash completion for susi-dev, load it with: source <(susi-dev completion bash)
+_susi_dev() {
+  local cur=${COMP_WORDS[COMP_CWORD]}
+  local candidates
+  candidates=$(susi-dev __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null)
+  if [ "$candidates" = ":files" ]; then
+    COMPREPLY=($(compgen -f -- "$cur"))
+  else
+    COMPREPLY=($(compgen -W "$candidates" -- "$cur"))
+  fi
+}
+complete -o default -F _susi_dev susi-dev
+`,
+	"zsh": `#compdef susi-dev
+# zsh completion for susi-dev, load it with: source <(susi-dev completion zsh)
+_susi_dev() {
+  local -a candidates
+  candidates=(${(f)"$(susi-dev __complete "${(@)words[2,CURRENT]}" 2>/dev/null)"})
+  if [[ "${candidates[1]}" == ":files" ]]; then
+    _files
+  else
+    compadd -a candidates
+  fi
+}
+compdef _susi_dev susi-dev
+`,
+	"fish": `# fish completion for susi-dev, load it with: susi-dev completion fish | source
+function __susi_dev_complete
+    set -l words (commandline -opc)
+    set -l current (commandline -ct)
+    if test (count $current) -eq 0
+        set current ''
+    end
+    set -l candidates (susi-dev __complete $words[2..-1] $current 2>/dev/null)
+    if test "$candidates" = ":files"
+        __fish_complete_path $current
+    else
+        printf '%s\n' $candidates
+    end
+end
+complete -c susi-dev -f -a '(__susi_dev_complete)'
+`,
+}
+
+// flagValues completes the values of flags, they are named alike in all commands
+var flagValues = map[string]func() []string{
+	"arch":            arch.Names,
+	"os":              func() []string { return systems },
+	"signer":          func() []string { return sign.Signers },
+	"connect-to":      func() []string { return completeNodes(nil) },
+	"format":          func() []string { return []string{"deb"} },
+	"output":          func() []string { return []string{completeFiles} },
+	"from":            func() []string { return []string{completeFiles} },
+	"passphrase-file": func() []string { return []string{completeFiles} },
+	"secret-key":      func() []string { return []string{completeFiles} },
+}
+
+// completeNodes returns the nodes of the project in the working directory
+func completeNodes([]string) []string {
+	myProject, err := project.Load()
+	if err != nil {
+		return nil
+	}
+	return myProject.IDs()
+}
+
+// completeComponents returns the built-in components and the ones of the project
+func completeComponents([]string) []string {
+	components.LoadDir("components")
+	return components.Names()
+}
+
+// complete returns the candidates for the last of words, the words typed after susi-dev
+func (c *command) complete(words []string) []string {
+	current := ""
+	if len(words) > 0 {
+		current, words = words[len(words)-1], words[:len(words)-1]
+	}
+	var f *flag.FlagSet
+	var positional []string
+	value := ""
+	for _, word := range words {
+		switch {
+		case word == "--dry-run" || word == "-dry-run":
+		case value != "":
+			value = ""
+		case len(c.subcommands) > 0:
+			if c = c.find(word); c == nil {
+				return nil
+			}
+		case strings.HasPrefix(word, "-") && !c.restAt(len(positional)):
+			if f == nil {
+				f = c.flagSet(c.name)
+			}
+			name := strings.TrimLeft(word, "-")
+			if defined := f.Lookup(name); defined != nil && !isBoolFlag(defined) {
+				value = name
+			}
+		default:
+			positional = append(positional, word)
+		}
+	}
+	switch {
+	case value != "":
+		if values, ok := c.values[value]; ok {
+			return values()
+		}
+		if values, ok := flagValues[value]; ok {
+			return values()
+		}
+		return nil
+	case len(c.subcommands) > 0:
+		if c == root && strings.HasPrefix(current, "-") {
+			return []string{"--dry-run", "--help"}
+		}
+		return c.names()
+	case strings.HasPrefix(current, "-") && !c.restAt(len(positional)):
+		var flags []string
+		c.flagSet(c.name).VisitAll(func(f *flag.Flag) {
+			flags = append(flags, "--"+f.Name)
+		})
+		return append(flags, "--help")
+	case len(positional) < len(c.params):
+		p := c.params[len(positional)]
+		if p.files {
+			return []string{completeFiles}
+		}
+		if p.complete != nil {
+			return p.complete(positional)
+		}
+	}
+	return nil
+}
+
+// restAt tells whether the positional argument i belongs to a rest parameter
+func (c *command) restAt(i int) bool {
+	for j, p := range c.params {
+		if p.rest && i >= j {
+			return true
+		}
+	}
+	return false
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
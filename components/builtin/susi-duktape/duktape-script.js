@@ -0,0 +1,4 @@
+susi.registerConsumer('duktape-example', function(event){
+  console.log(event.payload);
+});
+susi.publish({topic:'duktape-example',payload:42});
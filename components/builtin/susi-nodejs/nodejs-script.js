@@ -0,0 +1,10 @@
+var Susi = require('./susi');
+var susi = new Susi('localhost', 4000, '/etc/susi/keys/susi-nodejs.crt', '/etc/susi/keys/susi-nodejs.key', function() {
+  susi.registerProcessor('nodejs-example', function(evt) {
+		evt.payload = 42;
+		susi.ack(evt);
+  });
+  susi.publish({topic:'nodejs-example'},function(event){
+		console.log('The answer is', event.payload);
+	});
+});
@@ -0,0 +1,155 @@
+package components
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+
+	"github.com/webvariants/susi-dev/pki"
+)
+
+// TopicLists are the per connection topic lists of a cluster config
+var TopicLists = []string{"forwardConsumers", "forwardProcessors", "registerConsumers", "registerProcessors"}
+
+// Topics maps topic lists to topics, lists missing in the map are kept as they are
+type Topics map[string][]string
+
+// Connect issues a certificate for node in the pki of peer, copies it into the foreignKeys of node
+// and merges the peer into the cluster configs of node
+func Connect(node, peer, address string, topics Topics) error {
+	if err := pki.CreateCertificate(peer+"/pki", node); err != nil && !errors.Is(err, pki.ErrExists) {
+		return err
+	}
+	if err := CopyForeignKeys(node, peer); err != nil {
+		return err
+	}
+	return updateClusterConfigs(node, func(peers []interface{}) ([]interface{}, error) {
+		entry := map[string]interface{}{"port": 4000}
+		var kept []interface{}
+		index := -1
+		for _, p := range peers {
+			m, ok := p.(map[string]interface{})
+			if ok && m["id"] == peer {
+				entry = m
+				index = len(kept)
+			} else if ok && m["id"] == "" {
+				continue
+			}
+			kept = append(kept, p)
+		}
+		entry["id"] = peer
+		entry["addr"] = address
+		entry["cert"] = "/etc/susi/keys/" + node + "@" + peer + ".crt"
+		entry["key"] = "/etc/susi/keys/" + node + "@" + peer + ".key"
+		for _, list := range TopicLists {
+			if topics, ok := topics[list]; ok {
+				entry[list] = append([]string{}, topics...)
+			} else if _, ok := entry[list]; !ok {
+				entry[list] = []string{}
+			}
+		}
+		if index < 0 {
+			kept = append(kept, entry)
+		}
+		return kept, nil
+	})
+}
+
+// RenameNode moves the connection of node to peer over from the old name of node:
+// the certificate for the old name is revoked and the peer issues one for the new name
+func RenameNode(node, old, peer, address string) error {
+	if err := pki.Revoke(peer+"/pki", old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, file := range []string{old + "@" + peer + ".crt", old + "@" + peer + ".key"} {
+		if err := os.Remove(node + "/foreignKeys/" + file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return Connect(node, peer, address, nil)
+}
+
+// RenamePeer updates the connection of node to a renamed peer, the certificates keep working
+// because the ca of the peer does not change
+func RenamePeer(node, old, peer, address string) error {
+	renames := [][2]string{
+		{node + "@" + old + ".crt", node + "@" + peer + ".crt"},
+		{node + "@" + old + ".key", node + "@" + peer + ".key"},
+		{old + ".ca.crt", peer + ".ca.crt"},
+	}
+	for _, rename := range renames {
+		if err := os.Rename(node+"/foreignKeys/"+rename[0], node+"/foreignKeys/"+rename[1]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return updateClusterConfigs(node, func(peers []interface{}) ([]interface{}, error) {
+		for _, p := range peers {
+			if m, ok := p.(map[string]interface{}); ok && m["id"] == old {
+				m["id"] = peer
+				m["addr"] = address
+				m["cert"] = "/etc/susi/keys/" + node + "@" + peer + ".crt"
+				m["key"] = "/etc/susi/keys/" + node + "@" + peer + ".key"
+			}
+		}
+		return peers, nil
+	})
+}
+
+// removePeer removes a peer from the cluster configs of node
+func removePeer(node, peer string) error {
+	return updateClusterConfigs(node, func(peers []interface{}) ([]interface{}, error) {
+		var kept []interface{}
+		for _, p := range peers {
+			if m, ok := p.(map[string]interface{}); !ok || m["id"] != peer {
+				kept = append(kept, p)
+			}
+		}
+		return kept, nil
+	})
+}
+
+// updateClusterConfigs applies update to the peers in component.nodes of all cluster configs of node
+func updateClusterConfigs(node string, update func(peers []interface{}) ([]interface{}, error)) error {
+	for _, definition := range components {
+		if !definition.Cluster {
+			continue
+		}
+		file := fmt.Sprintf("%v/configs/%v", node, definition.ConfigFile())
+		data, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		config := map[string]interface{}{}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		}
+		component, _ := config["component"].(map[string]interface{})
+		if component == nil {
+			component = map[string]interface{}{}
+			config["component"] = component
+		}
+		peers, _ := component["nodes"].([]interface{})
+		peers, err = update(peers)
+		if err != nil {
+			return err
+		}
+		if peers == nil {
+			peers = []interface{}{}
+		}
+		component["nodes"] = peers
+		data, err = json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(file, append(data, '\n'), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,166 +2,208 @@ package components
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"html/template"
+	"io/fs"
 	"io/ioutil"
-	"log"
 	"os"
-	"os/exec"
-	"strings"
+	"path/filepath"
 
+	"github.com/webvariants/susi-dev/arch"
+	"github.com/webvariants/susi-dev/container"
+	"github.com/webvariants/susi-dev/executor"
 	"github.com/webvariants/susi-dev/pki"
+	"github.com/webvariants/susi-dev/sign"
+	"github.com/webvariants/susi-dev/source"
 )
 
-// Component is a interface for all susi components
-type Component interface {
-	Config() string
-	StartCommand() string
-	BuildContainer(node, gpgpass string)
-	ExtraShell(node string) string
-}
-
-var components map[string]Component
+var components = map[string]*Definition{}
 
 func init() {
-	components = make(map[string]Component)
-	components["susi-authenticator"] = new(susiAuthenticatorComponent)
-	components["susi-cluster"] = new(susiClusterComponent)
-	components["susi-core"] = new(susiCoreComponent)
-	components["susi-duktape"] = new(susiDuktapeComponent)
-	components["susi-leveldb"] = new(susiLevelDBComponent)
-	components["susi-mqtt"] = new(susiMQTTComponent)
-	components["susi-serial"] = new(susiSerialComponent)
-	components["susi-shell"] = new(susiShellComponent)
-	components["susi-statefile"] = new(susiStatefileComponent)
-	components["susi-udpserver"] = new(susiUDPServerComponent)
-	components["susi-webhooks"] = new(susiWebhooksComponent)
-	components["susi-gowebstack"] = new(susiWebstackComponent)
-	components["susi-nodejs"] = new(susiNodeJSComponent)
-	components["susi-go"] = new(susiGoComponent)
-	components["susi-caddy"] = new(susiCaddyComponent)
+	fsys, err := fs.Sub(builtin, "builtin")
+	if err == nil {
+		err = load(fsys)
+	}
+	if err != nil {
+		panic(err)
+	}
 }
 
-func filterStringList(s []string, fn func(string) bool) []string {
-	var p []string // == nil
-	for _, v := range s {
-		if fn(v) {
-			p = append(p, v)
+// Add adds a compnent to a node, settings change the parameters of its config
+func Add(node, component string, connectTo *string, connectToAddress *string, settings []Setting) error {
+	definition, err := Lookup(component)
+	if err != nil {
+		return err
+	}
+	if err := pki.CreateCertificate(node+"/pki", component); err != nil && !errors.Is(err, pki.ErrExists) {
+		return err
+	}
+	if err := createSystemdUnitFile(node, component); err != nil {
+		return err
+	}
+	if err := createConfigFile(node, definition, connectTo, connectToAddress, settings); err != nil {
+		return err
+	}
+	if err := definition.createAssets(node); err != nil {
+		return err
+	}
+
+	if *connectTo != "" {
+		address := *connectTo
+		if connectToAddress != nil {
+			address = *connectToAddress
+		}
+		if err := Connect(node, *connectTo, address, nil); err != nil {
+			return err
 		}
 	}
-	return p
+	return nil
 }
 
-// Add adds a compnent to a node
-func Add(node, component string, connectTo *string, connectToAddress *string) {
-	pki.CreateCertificate(node+"/pki", component)
-	createSystemdUnitFile(node, component)
-	createConfigFile(node, component, connectTo, connectToAddress)
-
-	extra := components[component].ExtraShell(node)
-	if extra != "" {
-		exec.Command("/bin/bash", "-c", extra).Run()
+// CopyForeignKeys copies the certificate a peer issued for node and the peer ca into the foreignKeys of node
+func CopyForeignKeys(node, peer string) error {
+	files := [][2]string{
+		{peer + "/pki/pki/issued/" + node + ".crt", node + "/foreignKeys/" + node + "@" + peer + ".crt"},
+		{peer + "/pki/pki/private/" + node + ".key", node + "/foreignKeys/" + node + "@" + peer + ".key"},
+		{peer + "/pki/pki/ca.crt", node + "/foreignKeys/" + peer + ".ca.crt"},
 	}
+	for _, file := range files {
+		if err := executor.Run(executor.Program("cp", "-f", file[0], file[1])); err != nil {
+			return fmt.Errorf("copying %v: %v", file[0], err)
+		}
+	}
+	return nil
+}
 
-	if *connectTo != "" {
-		pki.CreateCertificate(*connectTo+"/pki", node)
-		srcFolder := *connectTo + "/pki/pki/issued/" + node + ".crt"
-		destFolder := node + "/foreignKeys/" + node + "@" + *connectTo + ".crt"
-		exec.Command("cp", "-f", srcFolder, destFolder).Run()
-
-		srcFolder = *connectTo + "/pki/pki/private/" + node + ".key"
-		destFolder = node + "/foreignKeys/" + node + "@" + *connectTo + ".key"
-		exec.Command("cp", "-f", srcFolder, destFolder).Run()
+// Remove undoes Add, the certificate of the component is revoked
+func Remove(node, component string) error {
+	definition, err := Lookup(component)
+	if err != nil {
+		return err
+	}
+	files := []string{
+		fmt.Sprintf("%v/configs/%v.service", node, component),
+		fmt.Sprintf("%v/configs/%v", node, definition.ConfigFile()),
+	}
+	images, err := filepath.Glob(fmt.Sprintf("%v/containers/%v-latest-linux-*", node, component))
+	if err != nil {
+		return err
+	}
+	files = append(files, images...)
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := pki.Revoke(node+"/pki", component); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
 
-		srcFolder = *connectTo + "/pki/pki/ca.crt"
-		destFolder = node + "/foreignKeys/" + *connectTo + ".ca.crt"
-		exec.Command("cp", "-f", srcFolder, destFolder).Run()
+// UsesConnections tells whether the config of a component refers to the node it connects to
+func UsesConnections(component string) bool {
+	definition, err := Lookup(component)
+	return err == nil && (definition.Cluster || bytes.Contains(definition.Config, []byte(".ConnectTo")))
+}
 
+// Disconnect removes a peer from the cluster configs of node, deletes its foreign keys
+// and revokes the certificate the peer issued for node
+func Disconnect(node, peer string) error {
+	if err := removePeer(node, peer); err != nil {
+		return err
 	}
+	files := []string{
+		node + "/foreignKeys/" + node + "@" + peer + ".crt",
+		node + "/foreignKeys/" + node + "@" + peer + ".key",
+		node + "/foreignKeys/" + peer + ".ca.crt",
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := pki.Revoke(peer+"/pki", node); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
 }
 
 //Build builds a service container for the specified component
-func Build(node, component, gpgpass string) {
-	components[component].BuildContainer(node, gpgpass)
+func Build(runtime container.Runtime, node, component string, a *arch.Arch, signer sign.Signer) error {
+	definition, err := Lookup(component)
+	if err != nil {
+		return err
+	}
+	image := definition.NodeImage(node, a)
+	if err := container.Build(runtime, image); err != nil {
+		return fmt.Errorf("building %v of %v: %w", component, node, err)
+	}
+	if err := sign.Missing(signer, image.File(runtime)); err != nil {
+		return fmt.Errorf("signing %v of %v: %w", component, node, err)
+	}
+	return nil
 }
 
 //createSystemdUnitFile creates a unitfile and writes it to the node configs
-func createSystemdUnitFile(node, component string) {
-	unitfile := getUnitfile(component)
-	path := fmt.Sprintf("%v/configs/%v.service", node, component)
-	err := ioutil.WriteFile(path, []byte(unitfile), 0755)
+func createSystemdUnitFile(node, component string) error {
+	unitfile, err := getUnitfile(component)
 	if err != nil {
-		log.Print(err)
+		return err
 	}
+	path := fmt.Sprintf("%v/configs/%v.service", node, component)
+	return ioutil.WriteFile(path, []byte(unitfile), 0755)
 }
 
 //createConfigFile creates a config for a component and writes it to the node configs
-func createConfigFile(node, component string, connectTo, connectToAddress *string) {
-	config := getConfig(node, component, connectTo, connectToAddress)
-	if config != "" {
-		extension := "json"
-		if strings.HasPrefix(component, "vpn-") {
-			extension = "ovpn"
-		}
-		path := fmt.Sprintf("%v/configs/%v.%v", node, component, extension)
-		err := ioutil.WriteFile(path, []byte(config), 0755)
-		if err != nil {
-			log.Print("Error writing config file: ", err)
-		}
+func createConfigFile(node string, definition *Definition, connectTo, connectToAddress *string, settings []Setting) error {
+	data := configData{Node: node, Component: definition.Name, ConnectTo: *connectTo, ConnectToAddress: *connectTo}
+	if connectToAddress != nil {
+		data.ConnectToAddress = *connectToAddress
 	}
-}
-
-// List returns a list of components for a node
-func List(node string) []string {
-	script := fmt.Sprintf("ls %v/configs/*.service | cut -d. -f1|cut -d/ -f3", node)
-	cmd := exec.Command("/bin/bash", "-c", script)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
+	config, err := definition.RenderConfig(data, settings)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("rendering the config of %v: %w", definition.Name, err)
 	}
-	list := filterStringList(strings.Split(out.String(), "\n"), func(arg string) bool { return arg != "" })
-	return list
-}
-
-// getConfig returns the config for a susi component
-func getConfig(node, component string, connectTo, connectToAddress *string) string {
-	switch component {
-	case "susi-cluster":
-		{
-			id := *connectTo
-			addr := id
-			if connectToAddress != nil {
-				addr = *connectToAddress
+	if config != "" {
+		path := fmt.Sprintf("%v/configs/%v", node, definition.ConfigFile())
+		if _, err := os.Stat(path); err == nil {
+			fmt.Printf("keeping existing %v\n", path)
+			// the settings are still applied to the kept config
+			for _, setting := range settings {
+				if err := Set(node, definition.Name, setting); err != nil {
+					return err
+				}
 			}
-			key := node + "@" + *connectTo + ".key"
-			crt := node + "@" + *connectTo + ".crt"
-			return fmt.Sprintf(components[component].Config(), id, addr, crt, key)
+			return nil
 		}
-	default:
-		{
-			return components[component].Config()
+		if err := ioutil.WriteFile(path, []byte(config), 0755); err != nil {
+			return fmt.Errorf("writing the config of %v: %w", definition.Name, err)
 		}
 	}
+	return nil
 }
 
 // GetStartCommand returns the start command for a service
-func GetStartCommand(component string) string {
-	if c, ok := components[component]; ok {
-		return c.StartCommand()
+func GetStartCommand(component string) (string, error) {
+	definition, err := Lookup(component)
+	if err != nil {
+		return "", err
 	}
-	log.Fatal("no such component")
-	return ""
+	return definition.Start, nil
 }
 
 // getUnitfile returns a systemd unit file
-func getUnitfile(component string) string {
+func getUnitfile(component string) (string, error) {
 	type UnitData struct {
 		Component string
 		Start     string
 	}
-	start := GetStartCommand(component)
+	start, err := GetStartCommand(component)
+	if err != nil {
+		return "", err
+	}
 
 	tmplString := `[Unit]
 Description="{{.Component}} service"
@@ -178,68 +220,67 @@ WantedBy=multi-user.target
 
 	tmpl := template.Must(template.New("").Parse(tmplString))
 	buff := bytes.Buffer{}
-	tmpl.Execute(&buff, UnitData{component, start})
+	if err := tmpl.Execute(&buff, UnitData{component, start}); err != nil {
+		return "", err
+	}
 
-	return buff.String()
+	return buff.String(), nil
 }
 
-func execBuildScript(script string) {
-	cmd := exec.Command("sudo", "/bin/bash", "-c", script)
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
-	if err != nil {
-		log.Println("Error: ", err)
+// baseImage is alpine with the libraries of the susi alpine build for a
+func baseImage(a *arch.Arch) *container.Image {
+	return &container.Image{
+		Name: "susi.io/susi-base",
+		Run: []string{
+			`echo -en 'http://dl-4.alpinelinux.org/alpine/v3.5/main\n' > /etc/apk/repositories`,
+			"apk update",
+			"apk add libstdc++ libssl1.0 boost-system boost-program_options",
+		},
+		Copy:   []container.Copy{{Src: source.Dir("alpine", a) + "/lib", Dst: "/lib", Dir: true}},
+		Output: container.ImagePath("/var/lib/susi-dev/containers", "susi-base", a),
+		Cached: true,
+		Arch:   a,
 	}
 }
 
-func execSignScript(script string) {
-	cmd := exec.Command("/bin/bash", "-c", script)
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
-	if err != nil {
-		log.Println("Error: ", err)
+// newBaseImage describes a shared image which is only built once
+func newBaseImage(name string, base *container.Image, a *arch.Arch, env []container.Env, run ...string) *container.Image {
+	return &container.Image{
+		Name:   "susi.io/" + name,
+		Base:   base,
+		Env:    env,
+		Run:    run,
+		Output: container.ImagePath("/var/lib/susi-dev/containers", name, a),
+		Cached: true,
+		Arch:   a,
 	}
 }
 
-func signContainer(container, gpgpass string) {
-	if gpgpass != "" {
-		fmt.Printf("Signing %v...\n", container)
-		signScript := fmt.Sprintf(`
-			if ! test -f %v.asc; then
-				gpg --batch --passphrase %v --sign --detach-sign --armor %v
-			fi
-			`, container, gpgpass, container)
-		execSignScript(signScript)
+// nodeImage describes the image of a component with the keys, configs and assets of a node
+func nodeImage(node, component string, base *container.Image, a *arch.Arch, start string, extra ...container.Copy) *container.Image {
+	copies := append(extra,
+		container.Copy{Src: fmt.Sprintf("%v/pki/pki/issued/%v.crt", node, component), Dst: fmt.Sprintf("/etc/susi/keys/%v.crt", component)},
+		container.Copy{Src: fmt.Sprintf("%v/pki/pki/private/%v.key", node, component), Dst: fmt.Sprintf("/etc/susi/keys/%v.key", component)},
+		container.Copy{Src: fmt.Sprintf("%v/configs/%v.json", node, component), Dst: fmt.Sprintf("/etc/susi/%v.json", component), Optional: true},
+		container.Copy{Src: fmt.Sprintf("%v/configs/%v.conf", node, component), Dst: fmt.Sprintf("/etc/susi/%v.conf", component), Optional: true},
+		container.Copy{Src: node + "/assets", Dst: "/usr/share/susi", Dir: true},
+		container.Copy{Src: node + "/foreignKeys", Dst: "/etc/susi/keys", Dir: true},
+		container.Copy{Src: node + "/pki/pki/ca.crt", Dst: "/etc/susi/keys/ca.crt"},
+		container.Copy{Src: node + "/pki/pki/crl.pem", Dst: "/etc/susi/keys/crl.pem", Optional: true},
+		container.Copy{Src: ".hosts", Dst: "/etc/hosts"},
+	)
+	return &container.Image{
+		Name:   "susi.io/" + component,
+		Tag:    node,
+		Base:   base,
+		Copy:   copies,
+		Exec:   start,
+		Output: container.ImagePath(node+"/containers", component, a),
+		Arch:   a,
 	}
 }
 
-func buildBaseContainer() {
-	script := `
-		if ! test -f /var/lib/susi-dev/containers/susi-base-latest-linux-amd64.aci; then
-			mkdir -p /var/lib/susi-dev/containers
-			chmod 777 /var/lib/susi-dev/containers
-
-		  acbuild --debug begin
-		  # Name the ACI
-		  acbuild --debug set-name susi.io/susi-base
-		  # Based on alpine
-		  acbuild --debug dep add quay.io/coreos/alpine-sh
-		  acbuild --debug run -- /bin/sh -c "echo -en 'http://dl-4.alpinelinux.org/alpine/v3.3/main\n' > /etc/apk/repositories"
-		  acbuild --debug run -- apk update
-		  acbuild --debug run -- apk add libstdc++ libssl1.0 boost-system boost-program_options
-
-		  for lib in .build/alpine/lib/*.so; do
-		    acbuild --debug copy $lib /lib/$(basename $lib)
-		  done
-
-
-		  acbuild --debug write --overwrite /var/lib/susi-dev/containers/susi-base-latest-linux-amd64.aci
-		  acbuild --debug end
-		fi
-	`
-	if _, err := os.Stat("/var/lib/susi-dev/containers/susi-base-latest-linux-amd64.aci"); err != nil {
-		execBuildScript(script)
-	}
+// binary copies a susi binary of the alpine build for a into an image
+func binary(component string, a *arch.Arch) container.Copy {
+	return container.Copy{Src: source.Dir("alpine", a) + "/bin/" + component, Dst: "/usr/local/bin/" + component}
 }
@@ -0,0 +1,217 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// ConfigPath returns the file of the config of a component in node
+func ConfigPath(node, component string) (string, error) {
+	definition, err := Lookup(component)
+	if err != nil {
+		return "", err
+	}
+	if definition.ConfigExtension != "" && definition.ConfigExtension != "json" {
+		return "", fmt.Errorf("the config of %v is no json, edit %v/configs/%v instead", component, node, definition.ConfigFile())
+	}
+	return fmt.Sprintf("%v/configs/%v", node, definition.ConfigFile()), nil
+}
+
+// parsePath splits a path like component.ports[0].port into map keys and list indices
+func parsePath(path string) ([]interface{}, error) {
+	var steps []interface{}
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indices []interface{}
+		if open := strings.Index(part, "["); open >= 0 {
+			key = part[:open]
+			for rest := part[open:]; rest != ""; {
+				end := strings.Index(rest, "]")
+				if rest[0] != '[' || end < 0 {
+					return nil, fmt.Errorf("invalid path %v", path)
+				}
+				index, err := strconv.Atoi(rest[1:end])
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid index %v in path %v", rest[1:end], path)
+				}
+				indices = append(indices, index)
+				rest = rest[end+1:]
+			}
+		}
+		if key == "" && (len(steps) == 0 || len(indices) == 0) {
+			return nil, fmt.Errorf("invalid path %v", path)
+		}
+		if key != "" {
+			steps = append(steps, key)
+		}
+		steps = append(steps, indices...)
+	}
+	return steps, nil
+}
+
+// readConfig parses the json config of a component in node
+func readConfig(node, component string) (string, interface{}, error) {
+	file, err := ConfigPath(node, component)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", nil, err
+	}
+	var config interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", nil, fmt.Errorf("%v: %v", file, err)
+	}
+	return file, config, nil
+}
+
+// Get returns the value of a parameter or of a config path of a component in node
+func Get(node, component, name string) (interface{}, error) {
+	definition, err := Lookup(component)
+	if err != nil {
+		return nil, err
+	}
+	path := name
+	if parameter, ok := definition.Parameter(name); ok {
+		path = parameter.ConfigPath()
+	}
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	_, config, err := readConfig(node, component)
+	if err != nil {
+		return nil, err
+	}
+	return walk(component, config, steps)
+}
+
+// walk returns the value at steps in config
+func walk(component string, config interface{}, steps []interface{}) (interface{}, error) {
+	value := config
+	for i, step := range steps {
+		switch step := step.(type) {
+		case string:
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%v is no object", pathString(steps[:i]))
+			}
+			if value, ok = m[step]; !ok {
+				return nil, fmt.Errorf("%v has no %v", component, pathString(steps[:i+1]))
+			}
+		case int:
+			list, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%v is no list", pathString(steps[:i]))
+			}
+			if step >= len(list) {
+				return nil, fmt.Errorf("%v has no %v", component, pathString(steps[:i+1]))
+			}
+			value = list[step]
+		}
+	}
+	return value, nil
+}
+
+// Set sets a parameter or a config path of a component in node.
+// Missing objects are created, lists grow by one element at a time.
+func Set(node, component string, setting Setting) error {
+	definition, err := Lookup(component)
+	if err != nil {
+		return err
+	}
+	file, config, err := readConfig(node, component)
+	if err != nil {
+		return err
+	}
+	if config, err = definition.apply(config, setting); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(data, '\n'), 0755)
+}
+
+// apply returns config with the value of setting
+func (definition *Definition) apply(config interface{}, setting Setting) (interface{}, error) {
+	path, value, err := definition.resolve(setting)
+	if err != nil {
+		return nil, err
+	}
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return set(config, steps, value, steps)
+}
+
+// set returns current with value at steps, all are the steps of the whole path for errors
+func set(current interface{}, steps []interface{}, value interface{}, all []interface{}) (interface{}, error) {
+	if len(steps) == 0 {
+		return value, nil
+	}
+	done := all[:len(all)-len(steps)]
+	switch step := steps[0].(type) {
+	case string:
+		if current == nil {
+			current = map[string]interface{}{}
+		}
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v is no object", pathString(done))
+		}
+		child, err := set(m[step], steps[1:], value, all)
+		if err != nil {
+			return nil, err
+		}
+		m[step] = child
+		return m, nil
+	case int:
+		if current == nil {
+			current = []interface{}{}
+		}
+		list, ok := current.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%v is no list", pathString(done))
+		}
+		if step > len(list) {
+			return nil, fmt.Errorf("%v has only %v elements", pathString(done), len(list))
+		}
+		if step == len(list) {
+			list = append(list, nil)
+		}
+		child, err := set(list[step], steps[1:], value, all)
+		if err != nil {
+			return nil, err
+		}
+		list[step] = child
+		return list, nil
+	}
+	return current, nil
+}
+
+// pathString formats steps like the paths parsePath accepts
+func pathString(steps []interface{}) string {
+	path := ""
+	for _, step := range steps {
+		switch step := step.(type) {
+		case string:
+			if path != "" {
+				path += "."
+			}
+			path += step
+		case int:
+			path += fmt.Sprintf("[%v]", step)
+		}
+	}
+	if path == "" {
+		return "the config"
+	}
+	return path
+}
@@ -0,0 +1,294 @@
+package components
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/webvariants/susi-dev/arch"
+	"github.com/webvariants/susi-dev/container"
+)
+
+//go:embed builtin
+var builtin embed.FS
+
+// Definition describes a component, it is loaded from a json file
+type Definition struct {
+	Name  string `json:"name"`
+	Start string `json:"start"`
+	// Cluster components get the connections of the node merged into component.nodes of their config
+	Cluster bool `json:"cluster,omitempty"`
+	// Config is a json object or a string, both are rendered with text/template
+	Config json.RawMessage `json:"config,omitempty"`
+	// ConfigExtension defaults to json
+	ConfigExtension string          `json:"configExtension,omitempty"`
+	Image           ImageDefinition `json:"image"`
+	Ports           []Port          `json:"ports,omitempty"`
+	Files           []File          `json:"files,omitempty"`
+	// Assets maps files created in the node assets to files next to the definition
+	Assets map[string]string `json:"assets,omitempty"`
+	// Parameters are the typed settings of the config with their defaults
+	Parameters []Parameter `json:"parameters,omitempty"`
+
+	fsys fs.FS
+}
+
+// ImageDefinition describes how the image of a component is built
+type ImageDefinition struct {
+	// From is susi-base (default) or alpine
+	From         string            `json:"from,omitempty"`
+	Repositories []string          `json:"repositories,omitempty"`
+	Packages     []string          `json:"packages,omitempty"`
+	Env          map[string]string `json:"env,omitempty"`
+	Run          []string          `json:"run,omitempty"`
+	// Binary is copied from the susi build to /usr/local/bin
+	Binary string `json:"binary,omitempty"`
+}
+
+// Port is a port exposed by a component
+type Port struct {
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"`
+	Port     int    `json:"port"`
+}
+
+// File is an extra file of the project copied into the image
+type File struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+}
+
+// configData is available in config templates
+type configData struct {
+	Node             string
+	Component        string
+	ConnectTo        string
+	ConnectToAddress string
+	// Params are the values of the parameters by name
+	Params map[string]interface{}
+}
+
+// LoadDir loads all component definitions of a directory, existing definitions with the same name are replaced
+func LoadDir(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	return load(os.DirFS(dir))
+}
+
+func load(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		definition := &Definition{fsys: fsys}
+		if err := json.Unmarshal(data, definition); err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		}
+		if definition.Name == "" || definition.Start == "" {
+			return fmt.Errorf("%v: name and start are required", file)
+		}
+		switch definition.Image.From {
+		case "":
+			definition.Image.From = "susi-base"
+		case "susi-base", "alpine":
+		default:
+			return fmt.Errorf("%v: image.from must be susi-base or alpine", file)
+		}
+		if err := definition.checkParameters(); err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		}
+		components[definition.Name] = definition
+	}
+	return nil
+}
+
+// Names returns the names of all known components
+func Names() []string {
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the definition of a component
+func Lookup(component string) (*Definition, error) {
+	if definition, ok := components[component]; ok {
+		return definition, nil
+	}
+	return nil, fmt.Errorf("no such component: %v", component)
+}
+
+// ConfigFile returns the file name of the component config
+func (definition *Definition) ConfigFile() string {
+	extension := definition.ConfigExtension
+	if extension == "" {
+		extension = "json"
+	}
+	return definition.Name + "." + extension
+}
+
+// RenderConfig renders the config with the defaults of the parameters and settings,
+// it returns "" for components without config. Json configs are built as data structures,
+// templates in their strings are rendered, string configs are rendered as one template.
+func (definition *Definition) RenderConfig(data configData, settings []Setting) (string, error) {
+	if len(definition.Config) == 0 || string(definition.Config) == "null" {
+		if len(settings) > 0 {
+			return "", fmt.Errorf("%v has no config", definition.Name)
+		}
+		return "", nil
+	}
+	params, err := definition.params(nil)
+	if err != nil {
+		return "", err
+	}
+	data.Params = params
+	text := ""
+	if err := json.Unmarshal(definition.Config, &text); err == nil {
+		if data.Params, err = definition.params(settings); err != nil {
+			return "", err
+		}
+		return renderTemplate(definition.Name, text, data)
+	}
+	var config interface{}
+	if err := json.Unmarshal(definition.Config, &config); err != nil {
+		return "", err
+	}
+	if config, err = renderStrings(definition.Name, config, data); err != nil {
+		return "", err
+	}
+	for _, parameter := range definition.Parameters {
+		if config, err = definition.apply(config, Setting{Name: parameter.Name, Value: parameter.Default}); err != nil {
+			return "", err
+		}
+	}
+	for _, setting := range settings {
+		if config, err = definition.apply(config, setting); err != nil {
+			return "", err
+		}
+	}
+	out, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out) + "\n", nil
+}
+
+// renderStrings renders the strings of a json value as templates
+func renderStrings(name string, value interface{}, data configData) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		return renderTemplate(name, value, data)
+	case []interface{}:
+		for i, v := range value {
+			rendered, err := renderStrings(name, v, data)
+			if err != nil {
+				return nil, err
+			}
+			value[i] = rendered
+		}
+	case map[string]interface{}:
+		for k, v := range value {
+			rendered, err := renderStrings(name, v, data)
+			if err != nil {
+				return nil, err
+			}
+			value[k] = rendered
+		}
+	}
+	return value, nil
+}
+
+func renderTemplate(name, text string, data configData) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buff := bytes.Buffer{}
+	if err := tmpl.Execute(&buff, data); err != nil {
+		return "", err
+	}
+	return buff.String(), nil
+}
+
+// createAssets writes the scaffold assets into the node, existing files are kept
+func (definition *Definition) createAssets(node string) error {
+	for name, src := range definition.Assets {
+		file := path.Join(node, "assets", name)
+		if _, err := os.Stat(file); err == nil {
+			continue
+		}
+		data, err := fs.ReadFile(definition.fsys, src)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(file, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// baseImage returns the image the component image is built on
+func (definition *Definition) baseImage(a *arch.Arch) *container.Image {
+	parent := baseImage(a)
+	if definition.Image.From == "alpine" {
+		parent = nil
+	}
+	image := definition.Image
+	if len(image.Repositories) == 0 && len(image.Packages) == 0 && len(image.Env) == 0 && len(image.Run) == 0 {
+		return parent
+	}
+	var run []string
+	if len(image.Repositories) > 0 {
+		run = append(run, fmt.Sprintf(`echo -en '%v\n' > /etc/apk/repositories`, strings.Join(image.Repositories, `\n`)))
+	}
+	if len(image.Packages) > 0 {
+		run = append(run, "apk update", "apk add "+strings.Join(image.Packages, " "))
+	}
+	run = append(run, image.Run...)
+	names := make([]string, 0, len(image.Env))
+	for name := range image.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var env []container.Env
+	for _, name := range names {
+		env = append(env, container.Env{Name: name, Value: image.Env[name]})
+	}
+	return newBaseImage(definition.Name+"-base", parent, a, env, run...)
+}
+
+// NodeImage describes the image of the component on a node built for a
+func (definition *Definition) NodeImage(node string, a *arch.Arch) *container.Image {
+	var extra []container.Copy
+	if definition.Image.Binary != "" {
+		extra = append(extra, binary(definition.Image.Binary, a))
+	}
+	for _, file := range definition.Files {
+		extra = append(extra, container.Copy{Src: file.Src, Dst: file.Dst})
+	}
+	image := nodeImage(node, definition.Name, definition.baseImage(a), a, definition.Start, extra...)
+	for _, port := range definition.Ports {
+		image.Ports = append(image.Ports, container.Port{Name: port.Name, Protocol: port.Protocol, Port: port.Port})
+	}
+	return image
+}
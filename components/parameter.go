@@ -0,0 +1,197 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParameterTypes are the types of component parameters
+var ParameterTypes = []string{"string", "int", "number", "bool", "list"}
+
+// Parameter is a typed setting of a component config
+type Parameter struct {
+	// Name is used with --set and config set, like ports[0].port
+	Name string `json:"name"`
+	// Path is the place of the value in a json config, it defaults to component.$name
+	Path string `json:"path,omitempty"`
+	// Type is one of ParameterTypes, lists are lists of strings
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Description string      `json:"description,omitempty"`
+	// File parameters name a file below /etc/susi or /usr/share/susi, validate checks that the node installs it
+	File bool `json:"file,omitempty"`
+}
+
+// Setting is a value of a parameter or of a config path, given as name=value
+type Setting struct {
+	Name  string
+	Value interface{}
+}
+
+// ParseSetting splits name=value, the value is converted when the setting is applied
+func ParseSetting(setting string) (Setting, error) {
+	parts := strings.SplitN(setting, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return Setting{}, fmt.Errorf("invalid setting %v, use name=value", setting)
+	}
+	return Setting{Name: parts[0], Value: parts[1]}, nil
+}
+
+// ConfigPath returns the place of the parameter in a json config
+func (parameter *Parameter) ConfigPath() string {
+	if parameter.Path != "" {
+		return parameter.Path
+	}
+	return "component." + parameter.Name
+}
+
+// Convert returns value as the type of the parameter, strings like the ones of --set are parsed
+func (parameter *Parameter) Convert(value interface{}) (interface{}, error) {
+	text, isText := value.(string)
+	switch parameter.Type {
+	case "string":
+		if isText {
+			return text, nil
+		}
+	case "int":
+		if isText {
+			n, err := strconv.Atoi(text)
+			if err != nil {
+				return nil, fmt.Errorf("%v has to be an integer: %v", parameter.Name, text)
+			}
+			return n, nil
+		}
+		switch n := value.(type) {
+		case int:
+			return n, nil
+		case float64:
+			if n == math.Trunc(n) {
+				return int(n), nil
+			}
+		}
+	case "number":
+		if isText {
+			n, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%v has to be a number: %v", parameter.Name, text)
+			}
+			return n, nil
+		}
+		switch n := value.(type) {
+		case int:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+	case "bool":
+		if isText {
+			b, err := strconv.ParseBool(text)
+			if err != nil {
+				return nil, fmt.Errorf("%v has to be true or false: %v", parameter.Name, text)
+			}
+			return b, nil
+		}
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case "list":
+		if isText {
+			list := []interface{}{}
+			for _, item := range strings.Split(text, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			return list, nil
+		}
+		if items, ok := value.([]interface{}); ok {
+			list := []interface{}{}
+			for _, item := range items {
+				s, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("%v has to be a list of strings", parameter.Name)
+				}
+				list = append(list, s)
+			}
+			return list, nil
+		}
+	default:
+		return nil, fmt.Errorf("%v has the unknown type %v, use one of %v", parameter.Name, parameter.Type, strings.Join(ParameterTypes, ", "))
+	}
+	return nil, fmt.Errorf("%v has to be of type %v: %v", parameter.Name, parameter.Type, value)
+}
+
+// check fails if a value of a config is not of the type of the parameter, unlike Convert it does not parse strings
+func (parameter *Parameter) check(value interface{}) error {
+	if _, isText := value.(string); isText && parameter.Type != "string" {
+		return fmt.Errorf("%v has to be of type %v, not a string", parameter.Name, parameter.Type)
+	}
+	_, err := parameter.Convert(value)
+	return err
+}
+
+// Parameter returns the parameter with the given name
+func (definition *Definition) Parameter(name string) (*Parameter, bool) {
+	for i := range definition.Parameters {
+		if definition.Parameters[i].Name == name {
+			return &definition.Parameters[i], true
+		}
+	}
+	return nil, false
+}
+
+// resolve returns the config path and the typed value of a setting.
+// Other names than parameters are config paths, their string values are json or strings.
+func (definition *Definition) resolve(setting Setting) (string, interface{}, error) {
+	if parameter, ok := definition.Parameter(setting.Name); ok {
+		value, err := parameter.Convert(setting.Value)
+		return parameter.ConfigPath(), value, err
+	}
+	if text, ok := setting.Value.(string); ok {
+		var value interface{}
+		if err := json.Unmarshal([]byte(text), &value); err == nil {
+			return setting.Name, value, nil
+		}
+	}
+	return setting.Name, setting.Value, nil
+}
+
+// checkParameters fails on parameters with unknown types or defaults of the wrong type
+func (definition *Definition) checkParameters() error {
+	for i := range definition.Parameters {
+		parameter := &definition.Parameters[i]
+		if parameter.Name == "" {
+			return fmt.Errorf("parameters need a name")
+		}
+		if _, err := parsePath(parameter.ConfigPath()); err != nil {
+			return err
+		}
+		if _, err := parameter.Convert(parameter.Default); err != nil {
+			return fmt.Errorf("default of %v", err)
+		}
+	}
+	return nil
+}
+
+// params returns the values of the parameters by name for string configs, settings override the defaults
+func (definition *Definition) params(settings []Setting) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	for _, parameter := range definition.Parameters {
+		params[parameter.Name], _ = parameter.Convert(parameter.Default)
+	}
+	for _, setting := range settings {
+		parameter, ok := definition.Parameter(setting.Name)
+		if !ok {
+			return nil, fmt.Errorf("%v has no parameter %v", definition.Name, setting.Name)
+		}
+		value, err := parameter.Convert(setting.Value)
+		if err != nil {
+			return nil, err
+		}
+		params[parameter.Name] = value
+	}
+	return params, nil
+}
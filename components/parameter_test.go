@@ -0,0 +1,275 @@
+package components
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseSetting(t *testing.T) {
+	for _, test := range []struct {
+		setting, name, value string
+		ok                   bool
+	}{
+		{"mqtt-port=1884", "mqtt-port", "1884", true},
+		{"forward=a,b", "forward", "a,b", true},
+		{"component.url=http://x/?a=b", "component.url", "http://x/?a=b", true},
+		{"file=", "file", "", true},
+		{"=1", "", "", false},
+		{"mqtt-port", "", "", false},
+	} {
+		setting, err := ParseSetting(test.setting)
+		if (err == nil) != test.ok || test.ok && (setting.Name != test.name || setting.Value != test.value) {
+			t.Errorf("ParseSetting(%q) = %+v, %v", test.setting, setting, err)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	for _, test := range []struct {
+		typ   string
+		value interface{}
+		want  interface{}
+	}{
+		{"string", "localhost", "localhost"},
+		{"string", 1.0, nil},
+		{"int", "1884", 1884},
+		{"int", 1884.0, 1884},
+		{"int", 1884, 1884},
+		{"int", 1.5, nil},
+		{"int", "1.5", nil},
+		{"int", true, nil},
+		{"number", "1.5", 1.5},
+		{"number", 2, 2.0},
+		{"number", "x", nil},
+		{"bool", "true", true},
+		{"bool", "0", false},
+		{"bool", false, false},
+		{"bool", "yes", nil},
+		{"list", "a, b,,c", []interface{}{"a", "b", "c"}},
+		{"list", "", []interface{}{}},
+		{"list", []interface{}{"susi/#"}, []interface{}{"susi/#"}},
+		{"list", []interface{}{"a", 1.0}, nil},
+		{"list", nil, nil},
+		{"duration", "1s", nil},
+	} {
+		parameter := &Parameter{Name: "p", Type: test.typ}
+		value, err := parameter.Convert(test.value)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("converted %#v to %v %#v", test.value, test.typ, value)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(value, test.want) {
+			t.Errorf("Convert(%#v) to %v = %#v, %v, want %#v", test.value, test.typ, value, err, test.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for _, test := range []struct {
+		typ   string
+		value interface{}
+		ok    bool
+	}{
+		{"string", "/etc/susi/x.js", true},
+		{"int", 4000.0, true},
+		// configs are not parsed like --set, "4000" is a string in the json
+		{"int", "4000", false},
+		{"bool", "true", false},
+		{"list", "a,b", false},
+		{"list", []interface{}{"a"}, true},
+	} {
+		if err := (&Parameter{Name: "p", Type: test.typ}).check(test.value); (err == nil) != test.ok {
+			t.Errorf("check(%#v) as %v = %v", test.value, test.typ, err)
+		}
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	for _, test := range []struct {
+		parameter Parameter
+		want      string
+	}{
+		{Parameter{Name: "mqtt-port"}, "component.mqtt-port"},
+		{Parameter{Name: "baudrate", Path: "component.ports[0].baudrate"}, "component.ports[0].baudrate"},
+	} {
+		if path := test.parameter.ConfigPath(); path != test.want {
+			t.Errorf("path of %v is %v, want %v", test.parameter.Name, path, test.want)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	for _, test := range []struct {
+		path string
+		want []interface{}
+	}{
+		{"component.port", []interface{}{"component", "port"}},
+		{"component.ports[0].port", []interface{}{"component", "ports", 0, "port"}},
+		{"matrix[1][2]", []interface{}{"matrix", 1, 2}},
+		{"list[0].[1]", []interface{}{"list", 0, 1}},
+		{"", nil},
+		{"a..b", nil},
+		{"[0]", nil},
+		{"a[-1]", nil},
+		{"a[x]", nil},
+		{"a[0", nil},
+		{"a[0]b", nil},
+	} {
+		steps, err := parsePath(test.path)
+		if test.want == nil {
+			if err == nil {
+				t.Errorf("parsed %q to %v", test.path, steps)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(steps, test.want) {
+			t.Errorf("parsePath(%q) = %v, %v, want %v", test.path, steps, err, test.want)
+		}
+		if path := pathString(steps); test.path != "list[0].[1]" && path != test.path {
+			t.Errorf("pathString(%v) = %q, want %q", steps, path, test.path)
+		}
+	}
+}
+
+func decode(t *testing.T, text string) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestApply(t *testing.T) {
+	definition, err := Lookup("susi-serial")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		config, setting, want string
+	}{
+		{`{"component": {"ports": []}}`, "ports[0].port=/dev/ttyUSB0", `{"component": {"ports": [{"port": "/dev/ttyUSB0"}]}}`},
+		{`{"component": {"ports": [{"baudrate": 9600}]}}`, "ports[0].baudrate=115200", `{"component": {"ports": [{"baudrate": 115200}]}}`},
+		{`{}`, "component.extra.debug=true", `{"component": {"extra": {"debug": true}}}`},
+		{`{}`, "component.name=gw", `{"component": {"name": "gw"}}`},
+		{`{}`, `component.list=["a",1]`, `{"component": {"list": ["a", 1]}}`},
+		{`{"component": {"ports": []}}`, "component.ports[1].port=x", ""},
+		{`{"component": []}`, "component.port=1", ""},
+		{`{"component": {"ports": {}}}`, "component.ports[0]=1", ""},
+		{`{"component": {"ports": []}}`, "ports[0].baudrate=fast", ""},
+	} {
+		setting, err := ParseSetting(test.setting)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := definition.apply(decode(t, test.config), setting)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("applied %v to %v: %v", test.setting, test.config, config)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("applying %v to %v: %v", test.setting, test.config, err)
+			continue
+		}
+		// numbers are compared as json
+		got, _ := json.Marshal(config)
+		if want := decode(t, test.want); !reflect.DeepEqual(decode(t, string(got)), want) {
+			t.Errorf("applying %v to %v gives %s, want %v", test.setting, test.config, got, test.want)
+		}
+	}
+}
+
+func TestParams(t *testing.T) {
+	definition, err := Lookup("susi-mqtt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, err := definition.params([]Setting{{Name: "mqtt-port", Value: "8883"}, {Name: "subscribe", Value: "a/#,b/#"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"mqtt-addr": "localhost",
+		"mqtt-port": 8883,
+		"forward":   []interface{}{".*@mqtt"},
+		"subscribe": []interface{}{"a/#", "b/#"},
+	}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params are %v, want %v", params, want)
+	}
+	for _, setting := range []Setting{{Name: "unknown", Value: "1"}, {Name: "mqtt-port", Value: "tls"}} {
+		if _, err := definition.params([]Setting{setting}); err == nil {
+			t.Errorf("accepted %v=%v", setting.Name, setting.Value)
+		}
+	}
+}
+
+func TestGetAndSet(t *testing.T) {
+	node := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(node, "configs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(node, "configs", "susi-mqtt.json")
+	if err := ioutil.WriteFile(file, []byte(`{"component": {"mqtt-port": 1883}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Set(node, "susi-mqtt", Setting{Name: "mqtt-port", Value: "8883"}); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := Get(node, "susi-mqtt", "mqtt-port"); err != nil || value != 8883.0 {
+		t.Errorf("mqtt-port is %#v, %v after setting it", value, err)
+	}
+	if value, err := Get(node, "susi-mqtt", "component.mqtt-port"); err != nil || value != 8883.0 {
+		t.Errorf("component.mqtt-port is %#v, %v", value, err)
+	}
+	if _, err := Get(node, "susi-mqtt", "forward"); err == nil || !strings.Contains(err.Error(), "has no component.forward") {
+		t.Errorf("getting a missing value returned %v", err)
+	}
+	if err := Set(node, "susi-mqtt", Setting{Name: "mqtt-port", Value: "tls"}); err == nil {
+		t.Error("set a string as int")
+	}
+	if _, err := ConfigPath(node, "susi-caddy"); err == nil {
+		t.Error("the caddy config is no json, but has a config path")
+	}
+}
+
+func TestLoadChecksParameters(t *testing.T) {
+	for _, test := range []struct {
+		parameter, err string
+	}{
+		{`{"name": "port", "type": "int", "default": 80}`, ""},
+		{`{"name": "port", "type": "int", "default": "http"}`, "default of port"},
+		{`{"name": "port", "type": "port", "default": 80}`, "unknown type"},
+		{`{"type": "int", "default": 80}`, "need a name"},
+		{`{"name": "port", "path": "component.ports[x]", "type": "int", "default": 80}`, "invalid index"},
+	} {
+		fsys := fstest.MapFS{"test.json": {Data: []byte(`{"name": "susi-test", "start": "/bin/true", "parameters": [` + test.parameter + `]}`)}}
+		err := load(fsys)
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("loading %v returned %v, want %q", test.parameter, err, test.err)
+		}
+	}
+	delete(components, "susi-test")
+}
+
+func TestBuiltinParameters(t *testing.T) {
+	for _, name := range Names() {
+		definition, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := definition.checkParameters(); err != nil {
+			t.Errorf("%v: %v", name, err)
+		}
+	}
+}
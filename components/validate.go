@@ -0,0 +1,198 @@
+package components
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// installDirs are the directories on the target susi-dev installs files into
+var installDirs = []string{"/etc/susi/", "/usr/share/susi/"}
+
+// Validate checks a component of node against its definition: the json syntax of its config,
+// the keys and kinds of the default config, the types of the parameters and that the keys,
+// configs and assets referenced by the config and the start command are installed.
+// installed tells if the node installs a path on the target. The problems are returned as
+// messages, the error is for configs which can not be read at all.
+func Validate(node, component string, installed func(path string) bool) ([]string, error) {
+	definition, err := Lookup(component)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, field := range strings.Fields(definition.Start) {
+		// a missing config is reported below
+		if managed(field) && !installed(field) && field != "/etc/susi/"+definition.ConfigFile() {
+			problems = append(problems, fmt.Sprintf("%v: the start command uses %v, %v", component, field, missing(node, field)))
+		}
+	}
+	if len(definition.Config) == 0 || string(definition.Config) == "null" {
+		return problems, nil
+	}
+	file := fmt.Sprintf("%v/configs/%v", node, definition.ConfigFile())
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return append(problems, fmt.Sprintf("%v is missing, add %v again", file, component)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var defaults interface{}
+	if err := json.Unmarshal(definition.Config, &defaults); err != nil {
+		return nil, fmt.Errorf("definition of %v: %v", component, err)
+	}
+	if _, ok := defaults.(string); ok {
+		// string configs are checked by the start command of the component
+		return problems, nil
+	}
+	var config interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return append(problems, fmt.Sprintf("%v: %v", position(file, data, err), err)), nil
+	}
+	for _, problem := range compare(defaults, config, nil) {
+		problems = append(problems, fmt.Sprintf("%v: %v", file, problem))
+	}
+	for i := range definition.Parameters {
+		parameter := &definition.Parameters[i]
+		steps, _ := parsePath(parameter.ConfigPath())
+		value, err := walk(component, config, steps)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%v: parameter %v: %v", file, parameter.Name, err))
+			continue
+		}
+		if err := parameter.check(value); err != nil {
+			problems = append(problems, fmt.Sprintf("%v: %v", file, err))
+			continue
+		}
+		if path, ok := value.(string); ok && parameter.File && managed(path) && !installed(path) {
+			problems = append(problems, fmt.Sprintf("%v: %v refers to %v, %v", file, parameter.Name, path, missing(node, path)))
+		}
+	}
+	for _, path := range keyPaths(config) {
+		if !installed(path) {
+			problems = append(problems, fmt.Sprintf("%v: %v", file, missing(node, path)))
+		}
+	}
+	return problems, nil
+}
+
+// managed tells if path is in a directory susi-dev installs files into
+func managed(path string) bool {
+	for _, dir := range installDirs {
+		if strings.HasPrefix(path, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// missing describes a file the node does not install and where it comes from
+func missing(node, path string) string {
+	switch {
+	case strings.HasPrefix(path, "/etc/susi/keys/"):
+		return fmt.Sprintf("the pki of %v has no %v, add the component or connect the node again", node, strings.TrimPrefix(path, "/etc/susi/keys/"))
+	case strings.HasPrefix(path, "/usr/share/susi/"):
+		return fmt.Sprintf("%v/assets/%v does not exist", node, strings.TrimPrefix(path, "/usr/share/susi/"))
+	}
+	return fmt.Sprintf("%v/configs has no %v", node, strings.TrimPrefix(path, "/etc/susi/"))
+}
+
+// keyPaths returns the certificates and keys a config refers to, like cert and key of every connection
+func keyPaths(value interface{}) []string {
+	var paths []string
+	switch value := value.(type) {
+	case string:
+		if strings.HasPrefix(value, "/etc/susi/keys/") {
+			paths = append(paths, value)
+		}
+	case []interface{}:
+		for _, v := range value {
+			paths = append(paths, keyPaths(v)...)
+		}
+	case map[string]interface{}:
+		for _, key := range sortedKeys(value) {
+			paths = append(paths, keyPaths(value[key])...)
+		}
+	}
+	return paths
+}
+
+// compare returns the differences of a config to the default config of its definition:
+// the keys of the default config are required and their values have to be of the same kind,
+// other keys and the elements of lists are not checked
+func compare(defaults, config interface{}, steps []interface{}) []string {
+	switch defaults := defaults.(type) {
+	case nil:
+		return nil
+	case map[string]interface{}:
+		m, ok := config.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%v has to be an object, not %v", pathString(steps), kind(config))}
+		}
+		var problems []string
+		for _, key := range sortedKeys(defaults) {
+			child := append(append([]interface{}{}, steps...), key)
+			value, ok := m[key]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%v is missing", pathString(child)))
+				continue
+			}
+			problems = append(problems, compare(defaults[key], value, child)...)
+		}
+		return problems
+	}
+	if kind(defaults) != kind(config) {
+		return []string{fmt.Sprintf("%v has to be %v, not %v", pathString(steps), kind(defaults), kind(config))}
+	}
+	return nil
+}
+
+// kind names the json type of a value
+func kind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a bool"
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// position returns file:line:column of a json syntax error
+func position(file string, data []byte, err error) string {
+	var syntaxError *json.SyntaxError
+	if !errors.As(err, &syntaxError) {
+		return file
+	}
+	// the offset is behind the character the decoder stopped at
+	offset := syntaxError.Offset
+	if offset > 0 {
+		offset--
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("%v:%v:%v", file, line, column)
+}
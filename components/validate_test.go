@@ -0,0 +1,194 @@
+package components
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const mqttConfig = `{
+  "susi-addr": "localhost",
+  "susi-port": 4000,
+  "cert": "/etc/susi/keys/susi-mqtt.crt",
+  "key": "/etc/susi/keys/susi-mqtt.key",
+  "component": {
+    "mqtt-addr": "localhost",
+    "mqtt-port": 1883,
+    "forward": [".*@mqtt"],
+    "subscribe": ["susi/#"]
+  }
+}`
+
+// installedPaths installs the binary and the keys of susi-mqtt
+func installedPaths(path string) bool {
+	switch path {
+	case "/usr/local/bin/susi-mqtt", "/etc/susi/keys/susi-mqtt.crt", "/etc/susi/keys/susi-mqtt.key":
+		return true
+	}
+	return false
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		config    string
+		installed func(string) bool
+		want      []string
+	}{
+		{"valid", mqttConfig, installedPaths, nil},
+		{"syntax", "{\n  \"susi-port\": 4000,\n}", installedPaths, []string{"susi-mqtt.json:3:1: invalid character '}'"}},
+		{"missing key", strings.Replace(mqttConfig, `"susi-port": 4000,`, "", 1), installedPaths, []string{"susi-port is missing"}},
+		{"kind", strings.Replace(mqttConfig, `"susi-port": 4000`, `"susi-port": "4000"`, 1), installedPaths, []string{"susi-port has to be a number, not a string"}},
+		{"parameter", strings.Replace(mqttConfig, `"mqtt-port": 1883`, `"mqtt-port": 1883.5`, 1), installedPaths, []string{"mqtt-port"}},
+		{"missing parameter", strings.Replace(mqttConfig, `"forward": [".*@mqtt"],`, "", 1), installedPaths, []string{"parameter forward"}},
+		{"keys", mqttConfig, func(path string) bool { return path == "/usr/local/bin/susi-mqtt" }, []string{
+			"gw has no susi-mqtt.crt",
+			"gw has no susi-mqtt.key",
+		}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			node := filepath.Join(t.TempDir(), "gw")
+			if err := os.MkdirAll(filepath.Join(node, "configs"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(filepath.Join(node, "configs", "susi-mqtt.json"), []byte(test.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			problems, err := Validate(node, "susi-mqtt", test.installed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(problems) != len(test.want) {
+				t.Fatalf("problems are %q, want %q", problems, test.want)
+			}
+			for i, problem := range problems {
+				if !strings.Contains(problem, test.want[i]) {
+					t.Errorf("problem %v is %q, want %q", i, problem, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMissingConfig(t *testing.T) {
+	node := filepath.Join(t.TempDir(), "gw")
+	problems, err := Validate(node, "susi-mqtt", installedPaths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0], "is missing, add susi-mqtt again") {
+		t.Errorf("problems are %q", problems)
+	}
+	if _, err := Validate(node, "susi-unknown", installedPaths); err == nil {
+		t.Error("validated an unknown component")
+	}
+}
+
+func TestMissing(t *testing.T) {
+	for _, test := range []struct {
+		path, want string
+	}{
+		{"/etc/susi/keys/susi-core.crt", "the pki of gw has no susi-core.crt"},
+		{"/usr/share/susi/webstack/index.html", "gw/assets/webstack/index.html does not exist"},
+		{"/etc/susi/duktape.js", "gw/configs has no duktape.js"},
+	} {
+		if got := missing("gw", test.path); !strings.HasPrefix(got, test.want) {
+			t.Errorf("missing(%v) = %q, want %q", test.path, got, test.want)
+		}
+	}
+}
+
+func TestManaged(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/etc/susi/susi-core.json":      true,
+		"/usr/share/susi/webstack":      true,
+		"/usr/local/bin/susi-core":      false,
+		"/etc/ssl/certs/ca.pem":         false,
+		"localhost":                     false,
+		"/etc/susi-other/susi-core.crt": false,
+	} {
+		if managed(path) != want {
+			t.Errorf("managed(%v) is %v", path, !want)
+		}
+	}
+}
+
+func TestKeyPaths(t *testing.T) {
+	config := decode(t, `{
+  "cert": "/etc/susi/keys/susi-core.crt",
+  "connections": [
+    {"addr": "sensor", "cert": "/etc/susi/keys/sensor.crt", "key": "/etc/susi/keys/sensor.key"}
+  ],
+  "assets": "/usr/share/susi/webstack",
+  "port": 4000
+}`)
+	want := []string{"/etc/susi/keys/susi-core.crt", "/etc/susi/keys/sensor.crt", "/etc/susi/keys/sensor.key"}
+	if paths := keyPaths(config); !reflect.DeepEqual(paths, want) {
+		t.Errorf("key paths are %v, want %v", paths, want)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	defaults := `{"addr": "localhost", "port": 4000, "tls": true, "topics": ["a"], "extra": null, "component": {"file": "x"}}`
+	for _, test := range []struct {
+		config string
+		want   []string
+	}{
+		{`{"addr": "gw", "port": 4001, "tls": false, "topics": [], "extra": 1, "component": {"file": "y", "more": 1}, "other": 1}`, nil},
+		{`{"port": 4000, "tls": true, "topics": [], "extra": null, "component": {"file": "x"}}`, []string{"addr is missing"}},
+		{`{"addr": 1, "port": "4000", "tls": "yes", "topics": {}, "extra": null, "component": []}`, []string{
+			"addr has to be a string, not a number",
+			"component has to be an object, not a list",
+			"port has to be a number, not a string",
+			"tls has to be a bool, not a string",
+			"topics has to be a list, not an object",
+		}},
+		{`{"addr": "gw", "port": 4000, "tls": true, "topics": [], "extra": null, "component": {}}`, []string{"component.file is missing"}},
+		{`[]`, []string{"the config has to be an object, not a list"}},
+	} {
+		problems := compare(decode(t, defaults), decode(t, test.config), nil)
+		if !reflect.DeepEqual(problems, test.want) {
+			t.Errorf("comparing %v returned %q, want %q", test.config, problems, test.want)
+		}
+	}
+}
+
+func TestKind(t *testing.T) {
+	for _, test := range []struct {
+		json, want string
+	}{
+		{`null`, "null"},
+		{`"x"`, "a string"},
+		{`1.5`, "a number"},
+		{`false`, "a bool"},
+		{`[]`, "a list"},
+		{`{}`, "an object"},
+	} {
+		if got := kind(decode(t, test.json)); got != test.want {
+			t.Errorf("kind of %v is %v, want %v", test.json, got, test.want)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	for _, test := range []struct {
+		data, want string
+	}{
+		{`{"a": }`, "f.json:1:7"},
+		{"{\n  \"a\": 1\n  \"b\": 2\n}", "f.json:3:3"},
+		{"{\n\"a\": 1,\n}", "f.json:3:1"},
+	} {
+		var value interface{}
+		err := json.Unmarshal([]byte(test.data), &value)
+		if got := position("f.json", []byte(test.data), err); got != test.want {
+			t.Errorf("position of %v in %q is %v, want %v", err, test.data, got, test.want)
+		}
+	}
+	if got := position("f.json", nil, os.ErrNotExist); got != "f.json" {
+		t.Errorf("position of a non syntax error is %v", got)
+	}
+}
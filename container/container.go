@@ -1,44 +1,246 @@
 package container
 
 import (
-	"bytes"
 	"fmt"
-	"log"
 	"os"
-	"os/exec"
 	"strings"
+
+	"github.com/webvariants/susi-dev/arch"
+	"github.com/webvariants/susi-dev/executor"
+	"github.com/webvariants/susi-dev/project"
 )
 
-//Prepare prepares a pod
-func Prepare(node string) (uuid string) {
-	script := fmt.Sprintf("sudo rkt prepare %v/containers/*.aci", node)
-	cmd := exec.Command("/bin/bash", "-c", script)
-	var out bytes.Buffer
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = &out
-	err := cmd.Run()
-	if err != nil {
-		log.Println("Error: ", err)
+// DefaultRuntime is used when the project does not select a runtime
+const DefaultRuntime = "rkt"
+
+// Copy is a file or directory copied into an image
+type Copy struct {
+	Src string
+	Dst string
+	// Dir copies all files below Src recursively into Dst
+	Dir bool
+	// Optional copies are skipped when Src does not exist
+	Optional bool
+}
+
+// Port is a port exposed by an image
+type Port struct {
+	Name     string
+	Protocol string
+	Port     int
+}
+
+// Env is an environment variable set in an image
+type Env struct {
+	Name  string
+	Value string
+}
+
+// Image describes a container image independent of the runtime which builds it
+type Image struct {
+	Name string
+	Tag  string
+	// Base is built first and used as parent, nil means plain alpine
+	Base *Image
+	Env  []Env
+	// Run are shell commands executed while building
+	Run   []string
+	Copy  []Copy
+	Ports []Port
+	Exec  string
+	// Output is the image file without the runtime specific extension
+	Output string
+	// Cached images are only built when their output is missing
+	Cached bool
+	// Arch is the architecture of the image, nil means the default architecture
+	Arch *arch.Arch
+}
+
+// File returns the image file the runtime writes
+func (image *Image) File(runtime Runtime) string {
+	return image.Output + runtime.Extension()
+}
+
+// Architecture returns the architecture of the image
+func (image *Image) Architecture() *arch.Arch {
+	if image.Arch == nil {
+		a, _ := arch.Lookup(arch.Default)
+		return a
+	}
+	return image.Arch
+}
+
+// Reference returns name:tag, the tag ends with the architecture
+func (image *Image) Reference() string {
+	return Reference(image.Name, image.Tag, image.Architecture())
+}
+
+// Reference returns the reference of an image built for a, an empty tag is latest
+func Reference(name, tag string, a *arch.Arch) string {
+	if tag == "" {
+		tag = "latest"
+	}
+	return name + ":" + tag + a.Suffix()
+}
+
+// ImagePath returns the image file of name in dir without the runtime specific extension
+func ImagePath(dir, name string, a *arch.Arch) string {
+	return fmt.Sprintf("%v/%v-latest%v", dir, name, a.Suffix())
+}
+
+// Runtime builds and runs the container images of nodes
+type Runtime interface {
+	Name() string
+	// Extension is the file extension of written images
+	Extension() string
+	BuildImage(image *Image) error
+	// Start runs the component images of a node and records the pod in its state.
+	// insecure starts images the runtime itself would refuse because they are not signed.
+	Start(node *project.Node, images []NodeImage, insecure bool) error
+	Stop(node *project.Node) error
+	Status(node *project.Node) error
+	// PodStatus returns the state of the pod of a node and of its apps
+	PodStatus(node *project.Node) (*PodStatus, error)
+	Logs(node *project.Node, args []string) error
+	Enter(node *project.Node, app string) error
+}
+
+// PodStatus is the state of the pod of a node
+type PodStatus struct {
+	// Unit is the state of the systemd unit running the pod, only rkt pods have one
+	Unit string `json:"unit,omitempty"`
+	// Apps maps the components of the node to the state of their app,
+	// a node which has not been started has only stopped apps
+	Apps map[string]string `json:"apps"`
+}
+
+// stopped returns the status of a node without pod
+func stopped(node *project.Node) *PodStatus {
+	status := &PodStatus{Apps: map[string]string{}}
+	for _, component := range node.Components {
+		status.Apps[component] = "stopped"
+	}
+	return status
+}
+
+// parseStates reads lines of "name state" and returns the state of each of names, a missing state is unknown
+func parseStates(out string, names ...string) map[string]string {
+	found := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 {
+			found[fields[0]] = fields[1]
+		}
 	}
-	uuid = strings.Trim(out.String(), "\n")
-	return uuid
+	states := map[string]string{}
+	for _, name := range names {
+		states[name] = found[name]
+		if states[name] == "" {
+			states[name] = "unknown"
+		}
+	}
+	return states
+}
+
+// NodeImage is the image file of a component of a node
+type NodeImage struct {
+	Component string
+	File      string
 }
 
-// Run starts a pod for a node
-func Run(uuid, ip string) (systemdID string) {
-	script := fmt.Sprintf("sudo systemd-run -p Environment=CNI_ARGS=IP=%v rkt run-prepared %v", ip, uuid)
-	cmd := exec.Command("/bin/bash", "-c", script)
-	var out bytes.Buffer
-	cmd.Stderr = &out
-	cmd.Stdout = &out
-	err := cmd.Run()
+// NodeImages returns the images built for the components of a node, components without image are skipped
+func NodeImages(runtime Runtime, node *project.Node) ([]NodeImage, error) {
+	a, err := arch.Lookup(node.Arch)
 	if err != nil {
-		log.Println("Error: ", err)
-	}
-	text := out.String()
-	words := strings.Split(text, " ")
-	last := words[3]
-	words = strings.Split(last, ".")
-	systemdID = words[0]
-	return systemdID
+		return nil, err
+	}
+	var images []NodeImage
+	for _, component := range node.Components {
+		file := ImagePath(node.ID+"/containers", component, a) + runtime.Extension()
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+		images = append(images, NodeImage{Component: component, File: file})
+	}
+	return images, nil
+}
+
+// Runtimes lists the names accepted by New
+var Runtimes = []string{"rkt", "docker", "podman"}
+
+// Network is the network the pods are started in
+type Network struct {
+	// Subnet the addresses of the nodes are taken from, empty is project.DefaultSubnet
+	Subnet string
+	// DNS is the resolver of the pods, empty keeps the one of the runtime
+	DNS string
+}
+
+// New returns the runtime with the given name, its pods are started in network
+func New(name string, network Network) (Runtime, error) {
+	if network.Subnet == "" {
+		network.Subnet = project.DefaultSubnet
+	}
+	switch name {
+	case "", "rkt":
+		return &rktRuntime{network: network}, nil
+	case "docker", "podman":
+		return &ociRuntime{tool: name, network: network}, nil
+	}
+	return nil, fmt.Errorf("no such container runtime: %v (use one of %v)", name, strings.Join(Runtimes, ", "))
+}
+
+// Build builds an image and its bases with the given runtime.
+// Stale signatures of the image are removed.
+func Build(runtime Runtime, image *Image) error {
+	if image.Base != nil {
+		if err := Build(runtime, image.Base); err != nil {
+			return err
+		}
+	}
+	file := image.File(runtime)
+	if _, err := os.Stat(file); err == nil && image.Cached {
+		return nil
+	}
+	// the build steps run inside the image, so they are emulated for other architectures
+	if len(image.Run) > 0 && !executor.IsDryRun() {
+		if err := image.Architecture().CheckEmulation(); err != nil {
+			return fmt.Errorf("building %v: %w", image.Name, err)
+		}
+	}
+	if err := runtime.BuildImage(image); err != nil || executor.IsDryRun() {
+		return err
+	}
+	if err := os.Remove(file + ".asc"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// copies returns the copies of an image whose sources exist
+func (image *Image) copies() []Copy {
+	var copies []Copy
+	for _, c := range image.Copy {
+		if _, err := os.Stat(c.Src); err != nil && (c.Optional || c.Dir) {
+			continue
+		}
+		copies = append(copies, c)
+	}
+	return copies
+}
+
+// shellQuote quotes s for use in a bash script
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func runScript(script string) error {
+	return executor.Run(executor.Script(script))
+}
+
+func runScriptWithSudo(script string) error {
+	return executor.Run(executor.SudoScript(script))
+}
+
+func outputWithSudo(script string) (string, error) {
+	return executor.Output(executor.SudoScript(script))
 }
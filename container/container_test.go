@@ -0,0 +1,196 @@
+package container
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/webvariants/susi-dev/arch"
+	"github.com/webvariants/susi-dev/executor"
+	"github.com/webvariants/susi-dev/project"
+)
+
+// record replaces the default executor with a recorder for the test
+func record(t *testing.T) *executor.Recorder {
+	recorder := &executor.Recorder{}
+	previous := executor.Default
+	executor.Default = recorder
+	t.Cleanup(func() { executor.Default = previous })
+	return recorder
+}
+
+func lookup(t *testing.T, name string) *arch.Arch {
+	a, err := arch.Lookup(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// testImage is a component image on a base image with every kind of build step
+func testImage(a *arch.Arch) *Image {
+	base := &Image{Name: "susi.io/susi-base", Output: "containers/susi-base-latest" + a.Suffix(), Arch: a}
+	return &Image{
+		Name:   "susi.io/susi-core",
+		Tag:    "gw",
+		Base:   base,
+		Env:    []Env{{Name: "MODE", Value: "test run"}},
+		Run:    []string{"apk add curl"},
+		Copy:   []Copy{{Src: "bin/susi-core", Dst: "/usr/local/bin/susi-core"}, {Src: "missing", Dst: "/etc/missing", Optional: true}},
+		Ports:  []Port{{Name: "susi", Protocol: "tcp", Port: 4000}},
+		Exec:   "/usr/local/bin/susi-core -c /etc/susi/susi-core.json",
+		Output: "gw/containers/susi-core-latest" + a.Suffix(),
+		Arch:   a,
+	}
+}
+
+// contains fails the test for every line missing in script
+func contains(t *testing.T, script string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(script, line) {
+			t.Errorf("missing %q in:\n%v", line, script)
+		}
+	}
+}
+
+func only(t *testing.T, recorder *executor.Recorder) executor.Command {
+	t.Helper()
+	if len(recorder.Commands) != 1 {
+		t.Fatalf("recorded %v commands, want one: %v", len(recorder.Commands), recorder.Scripts())
+	}
+	return recorder.Commands[0]
+}
+
+func TestRktBuildImage(t *testing.T) {
+	recorder := record(t)
+	r := &rktRuntime{network: Network{Subnet: project.DefaultSubnet}}
+	if err := r.BuildImage(testImage(lookup(t, "amd64"))); err != nil {
+		t.Fatal(err)
+	}
+	command := only(t, recorder)
+	if !command.Sudo {
+		t.Error("acbuild does not run as root")
+	}
+	contains(t, command.Script,
+		"acbuild --debug begin 'containers/susi-base-latest-linux-amd64.aci'\n",
+		"acbuild --debug set-name susi.io/susi-core\n",
+		"acbuild --debug label add arch amd64\n",
+		"acbuild --debug environment add MODE 'test run'\n",
+		"acbuild --debug run -- /bin/sh -c 'apk add curl'\n",
+		"acbuild --debug copy 'bin/susi-core' '/usr/local/bin/susi-core'\n",
+		"acbuild --debug port add susi tcp 4000\n",
+		"acbuild --debug set-exec -- /usr/local/bin/susi-core -c /etc/susi/susi-core.json\n",
+		"acbuild --debug write --overwrite 'gw/containers/susi-core-latest-linux-amd64.aci'\n")
+	if strings.Contains(command.Script, "/etc/missing") {
+		t.Errorf("the missing optional copy is part of the script:\n%v", command.Script)
+	}
+}
+
+func TestRktBuildCrossImage(t *testing.T) {
+	recorder := record(t)
+	image := testImage(lookup(t, "arm"))
+	image.Base = nil
+	if err := (&rktRuntime{}).BuildImage(image); err != nil {
+		t.Fatal(err)
+	}
+	script := only(t, recorder).Script
+	contains(t, script,
+		"docker2aci docker://arm32v7/alpine:"+alpineVersion+"\n",
+		"acbuild --debug begin /var/lib/susi-dev/containers/alpine-latest-linux-arm.aci\n",
+		"acbuild --debug label add arch armv7l\n")
+	if strings.Contains(script, "quay.io/coreos/alpine-sh") {
+		t.Errorf("cross images depend on the amd64 alpine image:\n%v", script)
+	}
+}
+
+func TestOCIDockerfile(t *testing.T) {
+	r := &ociRuntime{tool: "docker", network: Network{Subnet: project.DefaultSubnet}}
+	want := `FROM susi.io/susi-base:latest-linux-arm64
+ENV MODE=test run
+RUN apk add curl
+COPY bin/susi-core /usr/local/bin/susi-core
+EXPOSE 4000/tcp
+ENTRYPOINT ["/usr/local/bin/susi-core","-c","/etc/susi/susi-core.json"]
+`
+	if dockerfile := r.Dockerfile(testImage(lookup(t, "arm64"))); dockerfile != want {
+		t.Errorf("Dockerfile is\n%v\nwant\n%v", dockerfile, want)
+	}
+	image := testImage(lookup(t, "amd64"))
+	image.Base = nil
+	if dockerfile := r.Dockerfile(image); !strings.HasPrefix(dockerfile, "FROM docker.io/library/alpine:"+alpineVersion+"\n") {
+		t.Errorf("Dockerfile without base starts with %q", strings.SplitN(dockerfile, "\n", 2)[0])
+	}
+}
+
+func TestOCIBuildImage(t *testing.T) {
+	recorder := record(t)
+	r := &ociRuntime{tool: "podman", network: Network{Subnet: project.DefaultSubnet}}
+	image := testImage(lookup(t, "arm64"))
+	if err := r.BuildImage(image); err != nil {
+		t.Fatal(err)
+	}
+	script := only(t, recorder).Script
+	contains(t, script,
+		"cat > \"$dockerfile\" <<'DOCKERFILE'\n"+r.Dockerfile(image)+"DOCKERFILE\n",
+		"cp -a --parents 'bin/susi-core' \"$context\"\n",
+		"podman image inspect susi.io/susi-base:latest-linux-arm64 >/dev/null 2>&1 || podman load -i 'containers/susi-base-latest-linux-arm64.tar'\n",
+		"podman build --platform linux/arm64 -f \"$dockerfile\" -t susi.io/susi-core:gw-linux-arm64 \"$context\"\n",
+		"podman save -o 'gw/containers/susi-core-latest-linux-arm64.tar' susi.io/susi-core:gw-linux-arm64\n")
+	if strings.Count(script, "cp -a --parents") != 1 {
+		t.Errorf("the build context holds more than the copied file:\n%v", script)
+	}
+}
+
+func TestOCIStart(t *testing.T) {
+	recorder := record(t)
+	r := &ociRuntime{tool: "docker", network: Network{Subnet: "10.1.0.0/24", DNS: "10.1.0.1"}}
+	node := &project.Node{ID: "gw", IP: "10.1.0.2", Components: []string{"susi-core"}}
+	images := []NodeImage{{Component: "susi-core", File: "gw/containers/susi-core-latest.tar"}}
+	if err := r.Start(node, images, false); err != nil {
+		t.Fatal(err)
+	}
+	script := only(t, recorder).Script
+	contains(t, script,
+		"ids=$(docker ps -aq --filter label=io.susi.node=gw)\n",
+		"docker network inspect susi >/dev/null 2>&1 || docker network create --subnet 10.1.0.0/24 susi\n",
+		"docker run -d --name susi-gw --label io.susi.node=gw --hostname gw --network susi --ip 10.1.0.2 --dns 10.1.0.1 "+ociPause+"\n",
+		"docker load -i 'gw/containers/susi-core-latest.tar'\n",
+		"docker run -d --name susi-gw-susi-core --label io.susi.node=gw --restart on-failure --network container:susi-gw susi.io/susi-core:gw-linux-amd64\n")
+	if strings.Index(script, "docker rm -f $ids") > strings.Index(script, "docker run") {
+		t.Errorf("the containers of gw are removed after the start:\n%v", script)
+	}
+	if node.State.PodID != "susi-gw" {
+		t.Errorf("pod id is %q, want susi-gw", node.State.PodID)
+	}
+}
+
+func TestOCIStartAfterFailure(t *testing.T) {
+	recorder := record(t)
+	r := &ociRuntime{tool: "podman", network: Network{Subnet: project.DefaultSubnet}}
+	// the previous start failed and did not record the pod
+	node := &project.Node{ID: "gw", IP: "172.16.28.2"}
+	if err := r.Start(node, nil, false); err != nil {
+		t.Fatal(err)
+	}
+	script := only(t, recorder).Script
+	removal := strings.Index(script, "podman ps -aq --filter label=io.susi.node=gw")
+	if removal < 0 || removal > strings.Index(script, "podman run") {
+		t.Errorf("the containers left by the failed start are not removed first:\n%v", script)
+	}
+}
+
+func TestOCIStartFailure(t *testing.T) {
+	recorder := record(t)
+	recorder.Respond = func(command executor.Command) (string, error) {
+		return "", errors.New("exit status 125")
+	}
+	r := &ociRuntime{tool: "docker", network: Network{Subnet: project.DefaultSubnet}}
+	node := &project.Node{ID: "gw", IP: "172.16.28.2", Components: []string{"susi-core"}}
+	if err := r.Start(node, nil, false); err == nil {
+		t.Fatal("start succeeded although the run script failed")
+	}
+	if node.State.PodID != "" {
+		t.Errorf("pod %v is recorded although it did not start", node.State.PodID)
+	}
+}
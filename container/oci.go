@@ -0,0 +1,188 @@
+package container
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/webvariants/susi-dev/arch"
+	"github.com/webvariants/susi-dev/project"
+)
+
+// alpineVersion is the alpine release images without base are built on
+const alpineVersion = "3.5"
+
+const (
+	ociNetwork = "susi"
+	// the pause container holds the network namespace all apps of a node share
+	ociPause = "registry.k8s.io/pause:3.9"
+)
+
+// ociRuntime builds OCI images from generated Dockerfiles and runs them with docker or podman
+type ociRuntime struct {
+	tool    string
+	network Network
+}
+
+func (r *ociRuntime) Name() string {
+	return r.tool
+}
+
+func (r *ociRuntime) Extension() string {
+	return ".tar"
+}
+
+// Dockerfile returns the Dockerfile for an image, paths are relative to the project
+func (r *ociRuntime) Dockerfile(image *Image) string {
+	from := fmt.Sprintf("docker.io/%v/alpine:%v", image.Architecture().Docker, alpineVersion)
+	if image.Base != nil {
+		from = image.Base.Reference()
+	}
+	dockerfile := fmt.Sprintf("FROM %v\n", from)
+	for _, env := range image.Env {
+		dockerfile += fmt.Sprintf("ENV %v=%v\n", env.Name, env.Value)
+	}
+	for _, run := range image.Run {
+		dockerfile += fmt.Sprintf("RUN %v\n", run)
+	}
+	for _, c := range image.copies() {
+		if c.Dir {
+			dockerfile += fmt.Sprintf("COPY %v/ %v/\n", c.Src, c.Dst)
+		} else {
+			dockerfile += fmt.Sprintf("COPY %v %v\n", c.Src, c.Dst)
+		}
+	}
+	for _, port := range image.Ports {
+		dockerfile += fmt.Sprintf("EXPOSE %v/%v\n", port.Port, port.Protocol)
+	}
+	if image.Exec != "" {
+		entrypoint, _ := json.Marshal(strings.Fields(image.Exec))
+		dockerfile += fmt.Sprintf("ENTRYPOINT %s\n", entrypoint)
+	}
+	return dockerfile
+}
+
+func (r *ociRuntime) BuildImage(image *Image) error {
+	// the Dockerfile is part of the script, so a dry run shows it
+	script := "set -e\ndockerfile=$(mktemp)\ncontext=$(mktemp -d)\ntrap 'rm -rf \"$dockerfile\" \"$context\"' EXIT\n"
+	script += fmt.Sprintf("cat > \"$dockerfile\" <<'DOCKERFILE'\n%vDOCKERFILE\n", r.Dockerfile(image))
+	// the daemon gets only the copied files as build context, the project holds the private keys of all nodes
+	for _, c := range image.copies() {
+		script += fmt.Sprintf("cp -a --parents %v \"$context\"\n", shellQuote(c.Src))
+	}
+	if image.Base != nil {
+		script += fmt.Sprintf("%v image inspect %v >/dev/null 2>&1 || %v load -i %v\n",
+			r.tool, image.Base.Reference(), r.tool, shellQuote(image.Base.File(r)))
+	}
+	script += fmt.Sprintf("%v build --platform %v -f \"$dockerfile\" -t %v \"$context\"\n", r.tool, image.Architecture().Platform, image.Reference())
+	script += fmt.Sprintf("mkdir -p %v\n", shellQuote(filepath.Dir(image.Output)))
+	script += fmt.Sprintf("rm -f %v\n", shellQuote(image.File(r)))
+	script += fmt.Sprintf("%v save -o %v %v\n", r.tool, shellQuote(image.File(r)), image.Reference())
+	script += fmt.Sprintf("chmod 644 %v\n", shellQuote(image.File(r)))
+	return runScriptWithSudo(script)
+}
+
+func (r *ociRuntime) pod(node *project.Node) string {
+	return "susi-" + node.ID
+}
+
+func (r *ociRuntime) app(node *project.Node, app string) string {
+	return r.pod(node) + "-" + app
+}
+
+// Start ignores insecure, docker and podman do not check image signatures
+func (r *ociRuntime) Start(node *project.Node, images []NodeImage, insecure bool) error {
+	a, err := arch.Lookup(node.Arch)
+	if err != nil {
+		return err
+	}
+	pod := r.pod(node)
+	label := "io.susi.node=" + node.ID
+	// a failed start leaves containers behind without a recorded pod, so they are removed whatever the state says
+	script := "set -e\n" + r.remove(node)
+	script += fmt.Sprintf("%v network inspect %v >/dev/null 2>&1 || %v network create --subnet %v %v\n", r.tool, ociNetwork, r.tool, r.network.Subnet, ociNetwork)
+	// the apps share the resolv.conf of the pause container
+	dns := ""
+	if r.network.DNS != "" {
+		dns = "--dns " + r.network.DNS + " "
+	}
+	script += fmt.Sprintf("%v run -d --name %v --label %v --hostname %v --network %v --ip %v %v%v\n", r.tool, pod, label, node.ID, ociNetwork, node.IP, dns, ociPause)
+	for _, image := range images {
+		script += fmt.Sprintf("%v load -i %v\n", r.tool, shellQuote(image.File))
+		script += fmt.Sprintf("%v run -d --name %v --label %v --restart on-failure --network container:%v %v\n",
+			r.tool, r.app(node, image.Component), label, pod, Reference("susi.io/"+image.Component, node.ID, a))
+	}
+	if err := runScriptWithSudo(script); err != nil {
+		return err
+	}
+	node.State.PodID = pod
+	return nil
+}
+
+// remove returns the script removing all containers of a node
+func (r *ociRuntime) remove(node *project.Node) string {
+	return fmt.Sprintf(`ids=$(%v ps -aq --filter label=io.susi.node=%v)
+if test -n "$ids"; then
+  %v rm -f $ids
+fi
+`, r.tool, node.ID, r.tool)
+}
+
+func (r *ociRuntime) Stop(node *project.Node) error {
+	node.State = project.State{}
+	return runScriptWithSudo(r.remove(node))
+}
+
+func (r *ociRuntime) Status(node *project.Node) error {
+	return runScriptWithSudo(fmt.Sprintf("%v ps -a --filter label=io.susi.node=%v", r.tool, node.ID))
+}
+
+// PodStatus reports the state of the container of each app
+func (r *ociRuntime) PodStatus(node *project.Node) (*PodStatus, error) {
+	if node.State.PodID == "" {
+		return stopped(node), nil
+	}
+	script := ""
+	for _, component := range node.Components {
+		script += fmt.Sprintf("echo \"%v $(%v inspect -f '{{.State.Status}}' %v 2>/dev/null || echo missing)\"\n", component, r.tool, r.app(node, component))
+	}
+	out, err := outputWithSudo(script)
+	if err != nil {
+		return nil, err
+	}
+	return &PodStatus{Apps: parseStates(out, node.Components...)}, nil
+}
+
+// Logs accepts the journalctl options -u $app, -f and -n $lines
+func (r *ociRuntime) Logs(node *project.Node, args []string) error {
+	var apps, options []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-u", "--unit":
+			if i+1 < len(args) {
+				apps = append(apps, strings.TrimSuffix(args[i+1], ".service"))
+				i++
+			}
+		case "-n", "--lines":
+			if i+1 < len(args) {
+				options = append(options, "--tail", args[i+1])
+				i++
+			}
+		default:
+			options = append(options, args[i])
+		}
+	}
+	if len(apps) == 0 {
+		apps = node.Components
+	}
+	script := ""
+	for _, app := range apps {
+		script += fmt.Sprintf("%v logs %v %v\n", r.tool, strings.Join(options, " "), r.app(node, app))
+	}
+	return runScriptWithSudo(script)
+}
+
+func (r *ociRuntime) Enter(node *project.Node, app string) error {
+	return runScript(fmt.Sprintf("sudo %v exec -it %v /bin/sh", r.tool, r.app(node, app)))
+}
@@ -0,0 +1,167 @@
+package container
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/webvariants/susi-dev/executor"
+	"github.com/webvariants/susi-dev/project"
+)
+
+// rktRuntime builds ACI images with acbuild and runs them as rkt pods under systemd
+type rktRuntime struct {
+	network Network
+}
+
+// rktNetwork is the network of pods outside of DefaultSubnet, the default rkt network covers DefaultSubnet
+const rktNetwork = "susi"
+
+// runPrepared returns the systemd-run arguments running a prepared pod with the address of a node,
+// other subnets than the default one need a network configuration
+func (r *rktRuntime) runPrepared(node *project.Node) (string, error) {
+	dns := ""
+	if r.network.DNS != "" {
+		dns = " --dns=" + r.network.DNS
+	}
+	if r.network.Subnet == project.DefaultSubnet {
+		return fmt.Sprintf("-p Environment=CNI_ARGS=IP=%v rkt run-prepared%v", node.IP, dns), nil
+	}
+	conf := fmt.Sprintf(`{"name": "%v", "type": "ptp", "ipMasq": true, "ipam": {"type": "host-local", "subnet": "%v", "routes": [{"dst": "0.0.0.0/0"}]}}`, rktNetwork, r.network.Subnet)
+	script := fmt.Sprintf("set -e\nmkdir -p /etc/rkt/net.d\necho %v > /etc/rkt/net.d/10-%v.conf\n", shellQuote(conf), rktNetwork)
+	if err := runScriptWithSudo(script); err != nil {
+		return "", fmt.Errorf("configuring the rkt network %v: %w", rktNetwork, err)
+	}
+	return fmt.Sprintf("rkt run-prepared --net=%v:IP=%v%v", rktNetwork, node.IP, dns), nil
+}
+
+func (r *rktRuntime) Name() string {
+	return "rkt"
+}
+
+func (r *rktRuntime) Extension() string {
+	return ".aci"
+}
+
+func (r *rktRuntime) BuildImage(image *Image) error {
+	script := fmt.Sprintf("set -e\nmkdir -p %v\n", shellQuote(filepath.Dir(image.Output)))
+	a := image.Architecture()
+	switch {
+	case image.Base != nil:
+		script += fmt.Sprintf("acbuild --debug begin %v\n", shellQuote(image.Base.File(r)))
+	case a.Cross():
+		// there is no alpine aci for other architectures, convert the official docker image
+		alpine := fmt.Sprintf("/var/lib/susi-dev/containers/alpine-latest%v.aci", a.Suffix())
+		script += fmt.Sprintf("if ! test -f %v; then\n  docker2aci docker://%v/alpine:%v\n  mv %v-alpine-%v.aci %v\nfi\n",
+			alpine, a.Docker, alpineVersion, a.Docker, alpineVersion, alpine)
+		script += fmt.Sprintf("acbuild --debug begin %v\n", alpine)
+	default:
+		script += "acbuild --debug begin\n"
+	}
+	script += "trap \"{ export EXT=$?; acbuild --debug end && exit $EXT; }\" EXIT\n"
+	if image.Base == nil && !a.Cross() {
+		script += "acbuild --debug dep add quay.io/coreos/alpine-sh\n"
+	}
+	script += fmt.Sprintf("acbuild --debug set-name %v\n", image.Name)
+	script += fmt.Sprintf("acbuild --debug label add arch %v\nacbuild --debug label add os linux\n", a.ACI)
+	for _, env := range image.Env {
+		script += fmt.Sprintf("acbuild --debug environment add %v %v\n", env.Name, shellQuote(env.Value))
+	}
+	for _, run := range image.Run {
+		script += fmt.Sprintf("acbuild --debug run -- /bin/sh -c %v\n", shellQuote(run))
+	}
+	for _, c := range image.copies() {
+		if c.Dir {
+			script += fmt.Sprintf("for f in $(cd %v && find . -type f); do\n  acbuild --debug copy %v/$f %v/${f#./}\ndone\n", shellQuote(c.Src), shellQuote(c.Src), shellQuote(c.Dst))
+		} else {
+			script += fmt.Sprintf("acbuild --debug copy %v %v\n", shellQuote(c.Src), shellQuote(c.Dst))
+		}
+	}
+	for _, port := range image.Ports {
+		script += fmt.Sprintf("acbuild --debug port add %v %v %v\n", port.Name, port.Protocol, port.Port)
+	}
+	if image.Exec != "" {
+		script += fmt.Sprintf("acbuild --debug set-exec -- %v\n", image.Exec)
+	}
+	script += fmt.Sprintf("acbuild --debug write --overwrite %v\n", shellQuote(image.File(r)))
+	return runScriptWithSudo(script)
+}
+
+func (r *rktRuntime) Start(node *project.Node, images []NodeImage, insecure bool) error {
+	if node.State.PodID != "" {
+		if err := r.Stop(node); err != nil {
+			return err
+		}
+	}
+	if len(images) == 0 {
+		return fmt.Errorf("there are no images for %v, build them first", node.ID)
+	}
+	options := ""
+	if insecure {
+		options = "--insecure-options=image "
+	}
+	files := make([]string, 0, len(images))
+	for _, image := range images {
+		files = append(files, shellQuote(image.File))
+	}
+	out, err := outputWithSudo(fmt.Sprintf("rkt prepare %v%v", options, strings.Join(files, " ")))
+	if err != nil {
+		return err
+	}
+	uuid := strings.Trim(out, "\n")
+	if executor.IsDryRun() {
+		uuid = "$uuid"
+	}
+	run, err := r.runPrepared(node)
+	if err != nil {
+		return err
+	}
+	out, err = outputWithSudo(fmt.Sprintf("systemd-run %v %v 2>&1", run, uuid))
+	if err != nil || executor.IsDryRun() {
+		return err
+	}
+	// systemd-run answers "Running as unit: run-r1234.service."
+	words := strings.Split(out, " ")
+	if len(words) < 4 {
+		return fmt.Errorf("unexpected systemd-run output: %v", out)
+	}
+	node.State.PodID = uuid
+	node.State.SystemdID = strings.Split(words[3], ".")[0]
+	return nil
+}
+
+func (r *rktRuntime) Stop(node *project.Node) error {
+	err := runScript(fmt.Sprintf("sudo systemctl stop %v\nsudo rkt rm %v\n", node.State.SystemdID, node.State.PodID))
+	node.State = project.State{}
+	return err
+}
+
+func (r *rktRuntime) Status(node *project.Node) error {
+	return runScript(fmt.Sprintf("sudo systemctl status %v", node.State.SystemdID))
+}
+
+// PodStatus asks systemd for the unit of the pod and systemd inside the pod for the apps
+func (r *rktRuntime) PodStatus(node *project.Node) (*PodStatus, error) {
+	if node.State.PodID == "" {
+		return stopped(node), nil
+	}
+	script := fmt.Sprintf("echo \"pod $(systemctl is-active %v || true)\"\n", node.State.SystemdID)
+	if len(node.Components) > 0 {
+		script += fmt.Sprintf("for app in %v; do\n  echo \"$app $(systemctl --machine rkt-%v is-active $app.service 2>/dev/null || true)\"\ndone\n",
+			strings.Join(node.Components, " "), node.State.PodID)
+	}
+	out, err := outputWithSudo(script)
+	if err != nil {
+		return nil, err
+	}
+	apps := parseStates(out, node.Components...)
+	return &PodStatus{Unit: parseStates(out, "pod")["pod"], Apps: apps}, nil
+}
+
+func (r *rktRuntime) Logs(node *project.Node, args []string) error {
+	return runScript(fmt.Sprintf("sudo journalctl -M rkt-%v %v", node.State.PodID, strings.Join(args, " ")))
+}
+
+func (r *rktRuntime) Enter(node *project.Node, app string) error {
+	return runScript(fmt.Sprintf("sudo rkt enter --app %v %v /bin/sh", app, node.State.PodID))
+}
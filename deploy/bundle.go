@@ -0,0 +1,139 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// manifestUnitsCommand prints the units listed in the MANIFEST given as argument
+const manifestUnitsCommand = `sed -n 's#^[0-9a-f]*  units/\(.*\)\.service$#\1#p'`
+
+// Bundle writes a release as self-contained gzipped tar for installing without network access.
+// The archive contains install.sh and uninstall.sh, install.sh sets up the same release layout as a deploy
+// and disables the removed components.
+func Bundle(release *Release, removed []string, w io.Writer) error {
+	return release.writeTar(w, "susi-"+release.Node+"/", []blob{
+		{"install.sh", 0755, []byte(installScript(release, removed))},
+		{"uninstall.sh", 0755, []byte(uninstallScript(release))},
+	})
+}
+
+// installScript installs the bundle it is part of, units which do not come up roll back to the previous release
+func installScript(release *Release, removed []string) string {
+	return fmt.Sprintf(`#!/bin/bash
+# installs the susi release of %[1]v, set ROOT to install below another directory
+set -e
+cd "$(dirname "$0")"
+if [ -z "$ROOT" ] && [ "$(id -u)" != 0 ]; then
+  echo "install.sh has to be run as root"
+  exit 1
+fi
+sha256sum -c %[2]v >/dev/null
+
+base=$ROOT%[3]v
+units=%[4]v
+previous=$(readlink $base/current || true)
+previous=${previous#releases/}
+previous_units=""
+if [ -n "$previous" ] && [ -f $base/current/%[2]v ]; then
+  previous_units=$(%[5]v $base/current/%[2]v)
+fi
+stale=%[6]v
+for unit in $previous_units; do
+  case " $units " in
+    *" $unit "*) ;;
+    *) stale="$stale $unit" ;;
+  esac
+done
+new_units=$units
+
+id=$(date -u +%%Y%%m%%dT%%H%%M%%SZ)
+while [ -e $base/releases/$id ]; do
+  sleep 1
+  id=$(date -u +%%Y%%m%%dT%%H%%M%%SZ)
+done
+echo "installing release $id of %[1]v..."
+mkdir -p $base/releases
+cp -Rp . $base/releases/$id
+rm -f $base/releases/$id/install.sh $base/releases/$id/uninstall.sh
+mkdir -p $base/releases/$id/etc/keys $base/releases/$id/units $base/releases/$id/share
+chown -R 0:0 $base/releases/$id 2>/dev/null || true
+
+%[7]v
+if [ -n "$units" ]; then
+  echo "restarting $units..."
+  for unit in $units; do
+    systemctl restart $unit.service || true
+  done
+  sleep 3
+  failed=""
+  for unit in $units; do
+    systemctl is-active --quiet $unit.service || failed="$failed $unit"
+  done
+  if [ -n "$failed" ]; then
+    echo "units failed to start:$failed"
+    if [ -z "$previous" ]; then
+      echo "there is no previous release to roll back to"
+      exit 1
+    fi
+    echo "rolling back to release $previous..."
+    failed_id=$id
+    id=$previous
+    units=$previous_units
+    stale=""
+    for unit in $new_units; do
+      case " $units " in
+        *" $unit "*) ;;
+        *) stale="$stale $unit" ;;
+      esac
+    done
+%[7]v
+    for unit in $units; do
+      systemctl restart $unit.service || true
+    done
+    rm -rf $base/releases/$failed_id
+    exit 1
+  fi
+fi
+cd $base/releases
+ls -1 | sort | grep -vx "$id" | head -n -4 | xargs -r rm -rf
+echo "release $id of %[1]v is installed"
+`, release.Node, ManifestFile, baseDir, shellQuote(strings.Join(release.Units, " ")), manifestUnitsCommand,
+		shellQuote(strings.Join(removed, " ")), activateScript("$ROOT"))
+}
+
+// uninstallScript removes an installation made by install.sh or a deploy and restores the directories found before
+func uninstallScript(release *Release) string {
+	return fmt.Sprintf(`#!/bin/bash
+# removes the susi installation of %[1]v, set ROOT if it was installed below another directory
+set -e
+base=$ROOT%[2]v
+if [ -f $base/current/%[3]v ]; then
+  for unit in $(%[4]v $base/current/%[3]v); do
+    systemctl disable --now $unit.service || true
+    rm -f $ROOT/etc/systemd/system/$unit.service
+  done
+fi
+for dir in bin lib; do
+  for link in $ROOT/usr/local/$dir/*; do
+    if [ -L "$link" ]; then
+      case "$(readlink "$link")" in
+        "$base/"*) rm -f "$link" ;;
+      esac
+    fi
+  done
+done
+for dest in $ROOT/etc/susi $ROOT/usr/share/susi; do
+  if [ -L "$dest" ]; then
+    rm -f "$dest"
+    if [ -e "$dest.pre-susi-dev" ]; then
+      mv "$dest.pre-susi-dev" "$dest"
+    fi
+  fi
+done
+rm -rf $base
+systemctl daemon-reload
+echo "susi is uninstalled"
+`, release.Node, baseDir, ManifestFile, manifestUnitsCommand)
+}
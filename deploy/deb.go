@@ -0,0 +1,195 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// debUnitDir is where packages install systemd units
+const debUnitDir = "/lib/systemd/system/"
+
+// Deb describes the debian package of a node
+type Deb struct {
+	Version    string
+	Maintainer string
+	// Depends is the package providing the susi binaries
+	Depends string
+}
+
+var debInvalid = regexp.MustCompile(`[^a-z0-9+.-]+`)
+
+// DebName returns the package name of a node
+func DebName(node string) string {
+	return "susi-" + strings.Trim(debInvalid.ReplaceAllString(strings.ToLower(node), "-"), "-")
+}
+
+// DebFile returns the file name of the package
+func (deb *Deb) DebFile(node string) string {
+	return fmt.Sprintf("%v_%v_all.deb", DebName(node), deb.Version)
+}
+
+// debPath returns where a file of a release is installed by the package
+func debPath(file string) string {
+	if strings.HasPrefix(file, "units/") {
+		return debUnitDir + strings.TrimPrefix(file, "units/")
+	}
+	return InstallPath(file)
+}
+
+// WriteDeb writes a debian package of a release. It installs the configs, keys, assets and units of the node,
+// its maintainer scripts enable and restart the units and disable the removed components.
+func (deb *Deb) WriteDeb(release *Release, removed []string, w io.Writer) error {
+	now := time.Now()
+	data := &bytes.Buffer{}
+	md5sums := ""
+	size := int64(0)
+	err := writeTarGz(data, func(archive *tar.Writer) error {
+		dirs := map[string]bool{}
+		for _, file := range release.Files {
+			target := debPath(file.Path)
+			for dir := path.Dir(target); dir != "/"; dir = path.Dir(dir) {
+				dirs[dir] = true
+			}
+		}
+		sorted := make([]string, 0, len(dirs))
+		for dir := range dirs {
+			sorted = append(sorted, dir)
+		}
+		sort.Strings(sorted)
+		for _, dir := range sorted {
+			header := &tar.Header{Name: "." + dir + "/", Mode: 0755, Typeflag: tar.TypeDir, ModTime: now}
+			if err := archive.WriteHeader(header); err != nil {
+				return err
+			}
+		}
+		for _, file := range release.Files {
+			content, err := ioutil.ReadFile(file.Source)
+			if err != nil {
+				return err
+			}
+			target := debPath(file.Path)
+			header := &tar.Header{Name: "." + target, Mode: int64(file.Mode), Size: int64(len(content)), Typeflag: tar.TypeReg, ModTime: now}
+			if err := archive.WriteHeader(header); err != nil {
+				return err
+			}
+			if _, err := archive.Write(content); err != nil {
+				return err
+			}
+			md5sums += fmt.Sprintf("%x  %v\n", md5.Sum(content), strings.TrimPrefix(target, "/"))
+			size += int64(len(content))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	units := strings.Join(release.Units, " ")
+	control := fmt.Sprintf(`Package: %v
+Version: %v
+Architecture: all
+Maintainer: %v
+Depends: %v
+Installed-Size: %v
+Section: misc
+Priority: optional
+Description: susi node %v
+ Configs, keys, assets and systemd units of the susi node %v.
+`, DebName(release.Node), deb.Version, deb.Maintainer, deb.Depends, (size+1023)/1024, release.Node, release.Node)
+	scripts := []blob{
+		{"control", 0644, []byte(control)},
+		{"md5sums", 0644, []byte(md5sums)},
+		{"postinst", 0755, []byte(fmt.Sprintf(`#!/bin/sh
+set -e
+if [ "$1" = configure ]; then
+  for unit in %v; do
+    systemctl disable --now $unit.service >/dev/null 2>&1 || true
+  done
+  systemctl daemon-reload
+  for unit in %v; do
+    systemctl enable $unit.service
+    systemctl restart $unit.service
+  done
+fi
+`, strings.Join(removed, " "), units))},
+		{"prerm", 0755, []byte(fmt.Sprintf(`#!/bin/sh
+set -e
+if [ "$1" = remove ]; then
+  for unit in %v; do
+    systemctl disable --now $unit.service || true
+  done
+fi
+`, units))},
+		{"postrm", 0755, []byte(`#!/bin/sh
+set -e
+systemctl daemon-reload || true
+`)},
+	}
+	controlTar := &bytes.Buffer{}
+	err = writeTarGz(controlTar, func(archive *tar.Writer) error {
+		for _, script := range scripts {
+			header := &tar.Header{Name: "./" + script.path, Mode: int64(script.mode), Size: int64(len(script.data)), Typeflag: tar.TypeReg, ModTime: now}
+			if err := archive.WriteHeader(header); err != nil {
+				return err
+			}
+			if _, err := archive.Write(script.data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return writeAr(w, now, []blob{
+		{"debian-binary", 0644, []byte("2.0\n")},
+		{"control.tar.gz", 0644, controlTar.Bytes()},
+		{"data.tar.gz", 0644, data.Bytes()},
+	})
+}
+
+// writeTarGz writes a gzipped tar with the entries written by fill, all entries are owned by root
+func writeTarGz(w io.Writer, fill func(*tar.Writer) error) error {
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	if err := fill(archive); err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// writeAr writes an ar archive as used by debian packages
+func writeAr(w io.Writer, modTime time.Time, files []blob) error {
+	if _, err := io.WriteString(w, "!<arch>\n"); err != nil {
+		return err
+	}
+	for _, file := range files {
+		header := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8o%-10d`\n", file.path, modTime.Unix(), 0, 0, uint32(0100000)|uint32(file.mode), len(file.data))
+		if _, err := io.WriteString(w, header); err != nil {
+			return err
+		}
+		if _, err := w.Write(file.data); err != nil {
+			return err
+		}
+		if len(file.data)%2 == 1 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -1,60 +1,295 @@
 package deploy
 
 import (
-	"bytes"
-	"html/template"
-	"log"
-	"os"
-	"os/exec"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/webvariants/susi-dev/executor"
 )
 
-//Raw deploys a raw installation to a target
-func Raw(node, target string) {
-	type DeployData struct {
-		Node   string
-		Target string
+// baseDir holds the releases and the link to the current release on the target
+const baseDir = "/var/lib/susi-dev"
+
+// Raw deploys a raw installation to a target, removed components are disabled and deleted on the target
+func Raw(node, target string, removed []string) error {
+	release, err := Collect(node)
+	if err != nil {
+		return err
+	}
+	conn, err := Dial(target)
+	if err != nil {
+		return err
 	}
+	defer conn.Close()
+	return NewDeployer(conn).Deploy(release, removed)
+}
 
-	tmplString := `pushd {{.Node}}
+// Deployer installs releases into versioned directories on a target and switches between them.
+// /etc/susi, /usr/share/susi and the unit files are links into the current release.
+type Deployer struct {
+	Target Target
+	// Root prefixes all paths on the target
+	Root string
+	// Sudo runs privileged commands with sudo
+	Sudo bool
+	// Settle is the time restarted units get before they are checked
+	Settle time.Duration
+	// Keep is the number of releases kept on the target
+	Keep int
+	// Log receives progress messages
+	Log func(format string, args ...interface{})
+}
 
-	keys="$(find pki/pki/private -type f ! -name 'ca.key')"
-	keys+=" $(find foreignKeys/ -type f)"
-	keys+=" $(find pki/pki/issued -type f) pki/pki/ca.crt"
-	keys+=" pki/pki/dh.pem"
+// NewDeployer returns a deployer for a target reached via ssh
+func NewDeployer(target Target) *Deployer {
+	settle := 3 * time.Second
+	if executor.IsDryRun() {
+		settle = 0
+	}
+	return &Deployer{
+		Target: target,
+		Sudo:   true,
+		Settle: settle,
+		Keep:   5,
+		Log: func(format string, args ...interface{}) {
+			fmt.Printf(format+"\n", args...)
+		},
+	}
+}
 
-	services="$(find configs -name "*.service" -exec basename {} \;)"
+func (d *Deployer) base() string {
+	return d.Root + baseDir
+}
 
-	ssh {{.Target}} "rm -rf ~/.susi-dev-temp/* && mkdir -p ~/.susi-dev-temp/keys && mkdir ~/.susi-dev-temp/configs && mkdir ~/.susi-dev-temp/assets"
+// run runs a script with root privileges on the target
+func (d *Deployer) run(script string) (string, error) {
+	return d.Target.Run(executor.Command{Script: "set -e\n" + script, Sudo: d.Sudo})
+}
 
-	scp $keys {{.Target}}:~/.susi-dev-temp/keys/
-	scp configs/* {{.Target}}:~/.susi-dev-temp/configs/
-	scp -r assets/* {{.Target}}:~/.susi-dev-temp/assets/
+// Current returns the id of the active release and its manifest, the id is "" before the first deploy
+func (d *Deployer) Current() (string, map[string]string, error) {
+	out, err := d.run(fmt.Sprintf("readlink %v/current || true", d.base()))
+	if err != nil {
+		return "", nil, err
+	}
+	id := path.Base(strings.TrimSpace(out))
+	if strings.TrimSpace(out) == "" {
+		return "", map[string]string{}, nil
+	}
+	manifest, err := d.run(fmt.Sprintf("cat %v/current/%v 2>/dev/null || true", d.base(), ManifestFile))
+	if err != nil {
+		return "", nil, err
+	}
+	return id, ParseManifest(manifest), nil
+}
 
-	sshCommand="sudo mkdir -p /etc/susi/keys && sudo mkdir -p /usr/share/susi"
-	sshCommand+=" && sudo cp ~/.susi-dev-temp/configs/*.json /etc/susi/ || true"
-	sshCommand+=" && sudo cp ~/.susi-dev-temp/configs/*.conf /etc/susi/ || true"
-	sshCommand+=" && sudo cp ~/.susi-dev-temp/configs/*.service /etc/systemd/system/ || true"
-	sshCommand+=" && sudo cp ~/.susi-dev-temp/keys/* /etc/susi/keys/ || true"
-	sshCommand+=" && sudo cp -rf ~/.susi-dev-temp/assets/* /usr/share/susi/ || true"
-	sshCommand+=" && sudo systemctl daemon-reload"
-	sshCommand+=" && sudo systemctl enable $services"
-	sshCommand+=" && sudo systemctl restart $services"
+// releaseID returns the id of a new release, the time of the deploy. Ids sort like the releases,
+// so if the previous release is not older, e.g. it was deployed from a machine whose clock is ahead,
+// the id of the previous release gets a counter.
+func releaseID(previous string, now time.Time) (string, error) {
+	id := now.UTC().Format("20060102T150405Z")
+	if id > previous {
+		return id, nil
+	}
+	n := 0
+	if i := strings.LastIndex(previous, "."); i >= 0 {
+		if _, err := fmt.Sscanf(previous[i+1:], "%d", &n); err != nil {
+			return "", fmt.Errorf("invalid release id %v on the target", previous)
+		}
+		previous = previous[:i]
+	}
+	if n >= 999 {
+		return "", fmt.Errorf("release %v on the target is newer than the local time %v, check the clocks", previous, id)
+	}
+	return fmt.Sprintf("%v.%03d", previous, n+1), nil
+}
 
-	ssh {{.Target}} "$(echo $sshCommand)"
+// Deploy uploads a release, switches to it and restarts the units whose files changed.
+// If a restarted unit does not come up, the previous release is restored.
+func (d *Deployer) Deploy(release *Release, removed []string) error {
+	previous, previousSums, err := d.Current()
+	if err != nil {
+		return err
+	}
+	id, err := releaseID(previous, time.Now())
+	if err != nil {
+		return err
+	}
+	dir := fmt.Sprintf("%v/releases/%v", d.base(), id)
+	tarball, err := release.tarball()
+	if err != nil {
+		return err
+	}
+	d.Log("uploading release %v of %v...", id, release.Node)
+	script := fmt.Sprintf("mkdir -p %v/etc/keys %v/units %v/share && tar xzf - -C %v", dir, dir, dir, dir)
+	_, err = d.Target.Run(executor.Command{Script: script, Sudo: d.Sudo, Stdin: tarball})
+	if err != nil {
+		return err
+	}
 
-  exit 0
-  `
+	previousUnits := manifestUnits(previousSums)
+	stale := append(subtract(previousUnits, release.Units), removed...)
+	changed := release.ChangedUnits(previousSums)
+	d.Log("activating release %v...", id)
+	if err := d.activate(id, release.Units, stale); err != nil {
+		return d.rollback(previous, previousUnits, release.Units, changed, id, err)
+	}
+	failed, err := d.restart(changed)
+	if err != nil {
+		return d.rollback(previous, previousUnits, release.Units, changed, id, err)
+	}
+	if len(failed) > 0 {
+		return d.rollback(previous, previousUnits, release.Units, changed, id, fmt.Errorf("units failed to start: %v", strings.Join(failed, ", ")))
+	}
+	_, err = d.run(fmt.Sprintf("cd %v/releases && ls -1 | sort | head -n -%v | xargs -r rm -rf", d.base(), d.Keep))
+	return err
+}
+
+// activate switches the current link to a release and links its units
+func (d *Deployer) activate(id string, units, stale []string) error {
+	script := fmt.Sprintf("base=%v\nid=%v\nunits=%v\nstale=%v\n", d.base(), id, shellQuote(strings.Join(units, " ")), shellQuote(strings.Join(stale, " ")))
+	_, err := d.run(script + activateScript(d.Root))
+	return err
+}
+
+// activateScript switches $base/current to the release $id, links /etc/susi, /usr/share/susi,
+// the binaries, libraries and the $units into it and disables the $stale units.
+// Paths on the target are prefixed with root.
+func activateScript(root string) string {
+	return fmt.Sprintf(`ln -sfn releases/$id $base/current.tmp
+mv -T $base/current.tmp $base/current
+for link in %[1]v/etc/susi:etc %[1]v/usr/share/susi:share; do
+  dest=${link%%:*}
+  if [ -e "$dest" ] && [ ! -L "$dest" ]; then
+    rm -rf "$dest.pre-susi-dev"
+    mv "$dest" "$dest.pre-susi-dev"
+  fi
+  mkdir -p "$(dirname "$dest")"
+  ln -sfn "$base/current/${link#*:}" "$dest"
+done
+mkdir -p %[1]v/etc/systemd/system
+for unit in $stale; do
+  systemctl disable --now $unit.service || true
+  rm -f %[1]v/etc/systemd/system/$unit.service
+done
+for unit in $units; do
+  ln -sfn $base/current/units/$unit.service %[1]v/etc/systemd/system/$unit.service
+done
+for dir in bin lib; do
+  mkdir -p %[1]v/usr/local/$dir
+  for file in $base/current/$dir/*; do
+    if [ -e "$file" ]; then
+      ln -sfn "$base/current/$dir/${file##*/}" "%[1]v/usr/local/$dir/${file##*/}"
+    fi
+  done
+  # links into releases which do not contain the file anymore
+  for link in %[1]v/usr/local/$dir/*; do
+    if [ -L "$link" ] && [ ! -e "$link" ]; then
+      case "$(readlink "$link")" in
+        "$base/current/"*) rm -f "$link" ;;
+      esac
+    fi
+  done
+done
+if [ -d $base/current/lib ] && command -v ldconfig >/dev/null; then
+  ldconfig || true
+fi
+systemctl daemon-reload
+for unit in $units; do
+  systemctl enable $unit.service
+done
+`, root)
+}
 
-	tmpl := template.Must(template.New("").Parse(tmplString))
-	buff := bytes.Buffer{}
-	tmpl.Execute(&buff, DeployData{node, target})
+// restart restarts units and returns those which are not active afterwards
+func (d *Deployer) restart(units []string) ([]string, error) {
+	if len(units) == 0 {
+		return nil, nil
+	}
+	d.Log("restarting %v...", strings.Join(units, ", "))
+	if _, err := d.run(fmt.Sprintf("systemctl restart %v", services(units))); err != nil {
+		return nil, err
+	}
+	time.Sleep(d.Settle)
+	out, err := d.run(fmt.Sprintf("for unit in %v; do systemctl is-active --quiet $unit.service || echo $unit; done", strings.Join(units, " ")))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(out), nil
+}
 
-	cmd := exec.Command("/bin/bash", "-c", buff.String())
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
+// rollback restores the previous release after the release id failed with cause
+func (d *Deployer) rollback(previous string, previousUnits, units, changed []string, id string, cause error) error {
+	if previous == "" {
+		return fmt.Errorf("deploy of release %v failed, there is no previous release to roll back to: %v", id, cause)
+	}
+	d.Log("deploy failed: %v", cause)
+	d.Log("rolling back to release %v...", previous)
+	if err := d.activate(previous, previousUnits, subtract(units, previousUnits)); err != nil {
+		return fmt.Errorf("rollback to %v failed: %v (deploy failed: %v)", previous, err, cause)
+	}
+	failed, err := d.restart(intersect(changed, previousUnits))
 	if err != nil {
-		log.Println("Error: ", err)
+		return fmt.Errorf("rolled back to release %v but restarting failed: %v (deploy failed: %v)", previous, err, cause)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("rolled back to release %v but units failed to start: %v (deploy failed: %v)", previous, strings.Join(failed, ", "), cause)
+	}
+	if _, err := d.run(fmt.Sprintf("rm -rf %v/releases/%v", d.base(), id)); err != nil {
+		return err
 	}
+	return fmt.Errorf("deploy failed, rolled back to release %v: %v", previous, cause)
+}
+
+// manifestUnits returns the units listed in a manifest
+func manifestUnits(sums map[string]string) []string {
+	var units []string
+	for file := range sums {
+		if strings.HasPrefix(file, "units/") && strings.HasSuffix(file, ".service") {
+			units = append(units, strings.TrimSuffix(strings.TrimPrefix(file, "units/"), ".service"))
+		}
+	}
+	sort.Strings(units)
+	return units
+}
+
+func services(units []string) string {
+	return strings.Join(units, ".service ") + ".service"
+}
+
+func subtract(list, remove []string) []string {
+	var result []string
+	for _, v := range list {
+		if !containsString(remove, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func intersect(list, keep []string) []string {
+	var result []string
+	for _, v := range list {
+		if containsString(keep, v) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func containsString(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
 
+// shellQuote quotes s for use in a bash script
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
 }
@@ -0,0 +1,262 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// systemctl logs its arguments, units whose installed config contains "broken" are not active
+const systemctl = `#!/bin/sh
+echo "$@" >> "$SYSTEMCTL_LOG"
+if [ "$1" = is-active ]; then
+  grep -q broken "$SYSTEMCTL_ROOT/etc/susi/${3%.service}.json" 2>/dev/null && exit 3
+fi
+exit 0
+`
+
+// testTarget is a deployer for a LocalTarget below a temporary root with a fake systemctl
+type testTarget struct {
+	*Deployer
+	log string
+}
+
+func newTestTarget(t *testing.T) *testTarget {
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "bin")
+	writeFile(t, filepath.Join(bin, "systemctl"), systemctl)
+	if err := os.Chmod(filepath.Join(bin, "systemctl"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	target := &testTarget{
+		Deployer: &Deployer{Target: &LocalTarget{}, Root: filepath.Join(dir, "root"), Keep: 5, Log: t.Logf},
+		log:      filepath.Join(dir, "systemctl.log"),
+	}
+	t.Setenv("PATH", bin+":"+os.Getenv("PATH"))
+	t.Setenv("SYSTEMCTL_LOG", target.log)
+	t.Setenv("SYSTEMCTL_ROOT", target.Root)
+	return target
+}
+
+// restarted returns the units restarted since the last call
+func (target *testTarget) restarted(t *testing.T) []string {
+	data, err := ioutil.ReadFile(target.log)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	os.Remove(target.log)
+	var units []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "restart" {
+			for _, service := range fields[1:] {
+				units = append(units, strings.TrimSuffix(service, ".service"))
+			}
+		}
+	}
+	return units
+}
+
+// current returns the release the current link points to
+func (target *testTarget) current(t *testing.T) string {
+	link, err := os.Readlink(target.base() + "/current")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Base(link)
+}
+
+func (target *testTarget) releases(t *testing.T) []string {
+	entries, err := ioutil.ReadDir(target.base() + "/releases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, entry := range entries {
+		ids = append(ids, entry.Name())
+	}
+	return ids
+}
+
+func writeFile(t *testing.T, file, content string) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newNode writes a node with the units a and b and a shared asset
+func newNode(t *testing.T) string {
+	node := t.TempDir()
+	for _, unit := range []string{"a", "b"} {
+		writeFile(t, filepath.Join(node, "configs", unit+".service"), "[Service]\nExecStart=/usr/local/bin/"+unit+"\n")
+		writeFile(t, filepath.Join(node, "configs", unit+".json"), `{"unit": "`+unit+`"}`)
+	}
+	writeFile(t, filepath.Join(node, "assets", "script.js"), "// shared\n")
+	return node
+}
+
+func collect(t *testing.T, node string) *Release {
+	release, err := Collect(node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return release
+}
+
+func equal(a, b []string) bool {
+	return strings.Join(a, " ") == strings.Join(b, " ")
+}
+
+func TestFirstDeploy(t *testing.T) {
+	target := newTestTarget(t)
+	node := newNode(t)
+	release := collect(t, node)
+
+	changes, err := target.Plan(release, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != len(release.Files) {
+		t.Fatalf("plan before the first deploy has %v changes, want one per file: %v", len(changes), changes)
+	}
+	for _, change := range changes {
+		if change.Op != '+' {
+			t.Errorf("plan before the first deploy: %v, want only added files", change)
+		}
+	}
+
+	if err := target.Deploy(release, nil); err != nil {
+		t.Fatal(err)
+	}
+	id := target.current(t)
+	if releases := target.releases(t); !equal(releases, []string{id}) {
+		t.Errorf("releases are %v, want %v", releases, id)
+	}
+	for _, file := range []string{"/etc/susi/a.json", "/usr/share/susi/script.js", "/etc/systemd/system/b.service", "/var/lib/susi-dev/current/" + ManifestFile} {
+		if _, err := os.Stat(target.Root + file); err != nil {
+			t.Errorf("%v is not installed: %v", file, err)
+		}
+	}
+	if units := target.restarted(t); !equal(units, []string{"a", "b"}) {
+		t.Errorf("restarted %v, want a b", units)
+	}
+
+	changes, err = target.Plan(release, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("plan after the deploy: %v, want no changes", changes)
+	}
+}
+
+func TestRedeployRestartsChangedUnits(t *testing.T) {
+	target := newTestTarget(t)
+	node := newNode(t)
+	if err := target.Deploy(collect(t, node), nil); err != nil {
+		t.Fatal(err)
+	}
+	first := target.current(t)
+	target.restarted(t)
+
+	writeFile(t, filepath.Join(node, "configs", "a.json"), `{"unit": "a", "changed": true}`)
+	release := collect(t, node)
+	changes, err := target.Plan(release, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Op != '~' || changes[0].Path != "etc/a.json" {
+		t.Errorf("plan: %v, want ~ /etc/susi/a.json", changes)
+	}
+	if err := target.Deploy(release, nil); err != nil {
+		t.Fatal(err)
+	}
+	if target.current(t) == first {
+		t.Errorf("current still points to %v", first)
+	}
+	if units := target.restarted(t); !equal(units, []string{"a"}) {
+		t.Errorf("restarted %v, want only a", units)
+	}
+
+	if err := target.Deploy(collect(t, node), nil); err != nil {
+		t.Fatal(err)
+	}
+	if units := target.restarted(t); len(units) != 0 {
+		t.Errorf("restarted %v for an unchanged release, want none", units)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	target := newTestTarget(t)
+	node := newNode(t)
+	if err := target.Deploy(collect(t, node), nil); err != nil {
+		t.Fatal(err)
+	}
+	first := target.current(t)
+
+	writeFile(t, filepath.Join(node, "configs", "b.json"), `{"unit": "broken"}`)
+	err := target.Deploy(collect(t, node), nil)
+	if err == nil || !strings.Contains(err.Error(), "rolled back to release "+first) {
+		t.Fatalf("deploy with a failing unit returned %v, want a rollback to %v", err, first)
+	}
+	if current := target.current(t); current != first {
+		t.Errorf("current points to %v after the rollback, want %v", current, first)
+	}
+	if releases := target.releases(t); !equal(releases, []string{first}) {
+		t.Errorf("releases are %v after the rollback, want only %v", releases, first)
+	}
+	data, err := ioutil.ReadFile(target.Root + "/etc/susi/b.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"b"`) {
+		t.Errorf("/etc/susi/b.json is %s after the rollback, want the previous config", data)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	target := newTestTarget(t)
+	var old []string
+	for _, id := range []string{"20000101T000000Z", "20000102T000000Z", "20000103T000000Z", "20000104T000000Z", "20000105T000000Z", "20000106T000000Z"} {
+		if err := os.MkdirAll(target.base()+"/releases/"+id, 0755); err != nil {
+			t.Fatal(err)
+		}
+		old = append(old, id)
+	}
+	if err := target.Deploy(collect(t, newNode(t)), nil); err != nil {
+		t.Fatal(err)
+	}
+	want := append(old[2:], target.current(t))
+	if releases := target.releases(t); !equal(releases, want) {
+		t.Errorf("releases are %v, want the newest five %v", releases, want)
+	}
+}
+
+func TestReleaseID(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		previous, want string
+	}{
+		{"", "20261018T120000Z"},
+		{"20261018T115959Z", "20261018T120000Z"},
+		{"20261018T115959Z.004", "20261018T120000Z"},
+		{"20261018T120000Z", "20261018T120000Z.001"},
+		{"20261018T180000Z", "20261018T180000Z.001"},
+		{"20261018T180000Z.009", "20261018T180000Z.010"},
+		{"20261018T180000Z.999", ""},
+		{"20261018T180000Z.x", ""},
+	} {
+		id, err := releaseID(test.previous, now)
+		if id != test.want || (err != nil) != (test.want == "") {
+			t.Errorf("releaseID(%q) = %q, %v, want %q", test.previous, id, err, test.want)
+		}
+		if err == nil && id <= test.previous {
+			t.Errorf("release %v does not sort after %v", id, test.previous)
+		}
+	}
+}
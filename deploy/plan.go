@@ -0,0 +1,131 @@
+package deploy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change is a difference between a release and the files installed on a target
+type Change struct {
+	// Op is '+' for added, '~' for changed and '-' for removed files
+	Op   byte
+	Path string
+	// Old and New are the sums of the file on the target and in the release
+	Old string
+	New string
+}
+
+// Key reports whether the file is a certificate or key, their content is only shown by fingerprint
+func (change Change) Key() bool {
+	return strings.HasPrefix(change.Path, "etc/keys/")
+}
+
+func (change Change) String() string {
+	line := fmt.Sprintf("%c %v", change.Op, InstallPath(change.Path))
+	if change.Key() {
+		switch change.Op {
+		case '+':
+			line += fmt.Sprintf(" (sha256 %v)", fingerprint(change.New))
+		case '~':
+			line += fmt.Sprintf(" (sha256 %v -> %v)", fingerprint(change.Old), fingerprint(change.New))
+		case '-':
+			line += fmt.Sprintf(" (sha256 %v)", fingerprint(change.Old))
+		}
+	}
+	return line
+}
+
+// fingerprint shortens a sha256 sum for display
+func fingerprint(sum string) string {
+	if len(sum) > 16 {
+		return sum[:16]
+	}
+	return sum
+}
+
+// Plan compares the release of a node with the files installed on a target
+func Plan(node, target string, removed []string) ([]Change, error) {
+	release, err := Collect(node)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := Dial(target)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return NewDeployer(conn).Plan(release, removed)
+}
+
+// Plan compares a release with the files installed on the target.
+// Files below /etc/susi and /usr/share/susi which are not part of the release are reported as removed,
+// as well as units of the current release and removed components which the release does not contain.
+func (d *Deployer) Plan(release *Release, removed []string) ([]Change, error) {
+	_, previousSums, err := d.Current()
+	if err != nil {
+		return nil, err
+	}
+	units := append(append(manifestUnits(previousSums), release.Units...), removed...)
+	installed, err := d.Installed(units)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, file := range release.Files {
+		old, ok := installed[file.Path]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Op: '+', Path: file.Path, New: file.Sum})
+		case old != file.Sum:
+			changes = append(changes, Change{Op: '~', Path: file.Path, Old: old, New: file.Sum})
+		}
+	}
+	current := release.Sums()
+	for path, old := range installed {
+		if _, ok := current[path]; !ok {
+			changes = append(changes, Change{Op: '-', Path: path, Old: old})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// Installed returns the sums of the files installed on the target by release path.
+// Only the given units are checked, other units on the target are not managed by susi-dev.
+func (d *Deployer) Installed(units []string) (map[string]string, error) {
+	unitFiles := []string{}
+	for _, unit := range units {
+		unitFiles = append(unitFiles, fmt.Sprintf("%v/etc/systemd/system/%v.service", d.Root, unit))
+	}
+	script := fmt.Sprintf(`for dir in %v/etc/susi %v/usr/share/susi; do
+  if [ -d "$dir" ]; then find -L "$dir" -type f -exec sha256sum {} +; fi
+done
+for unit in %v; do
+  if [ -f "$unit" ]; then sha256sum "$unit"; fi
+done
+`, d.Root, d.Root, strings.Join(unitFiles, " "))
+	out, err := d.run(script)
+	if err != nil {
+		return nil, err
+	}
+	installed := map[string]string{}
+	for file, sum := range ParseManifest(out) {
+		if path, ok := releasePath(strings.TrimPrefix(file, d.Root)); ok {
+			installed[path] = sum
+		}
+	}
+	return installed, nil
+}
+
+// releasePath is the inverse of InstallPath
+func releasePath(path string) (string, bool) {
+	for _, dir := range installDirs {
+		if strings.HasPrefix(path, dir[1]) {
+			return dir[0] + strings.TrimPrefix(path, dir[1]), true
+		}
+	}
+	return "", false
+}
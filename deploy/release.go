@@ -0,0 +1,296 @@
+package deploy
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestFile lists the sha256 sums of all files of a release in sha256sum format
+const ManifestFile = "MANIFEST"
+
+// File is a file of a release
+type File struct {
+	// Path is relative to the release directory
+	Path   string
+	Source string
+	Mode   os.FileMode
+	Sum    string
+}
+
+// Release is the set of files installed for a node
+type Release struct {
+	Node string
+	// Units are the systemd units of the node without .service
+	Units []string
+	Files []File
+}
+
+// installDirs maps the directories of a release to their location on the target
+var installDirs = [][2]string{
+	{"units/", "/etc/systemd/system/"},
+	{"etc/", "/etc/susi/"},
+	{"share/", "/usr/share/susi/"},
+	{"bin/", "/usr/local/bin/"},
+	{"lib/", "/usr/local/lib/"},
+}
+
+// InstallPath returns where a file of a release is installed on the target
+func InstallPath(path string) string {
+	for _, dir := range installDirs {
+		if strings.HasPrefix(path, dir[0]) {
+			return dir[1] + strings.TrimPrefix(path, dir[0])
+		}
+	}
+	return path
+}
+
+// Collect gathers the configs, units, keys and assets of a node
+func Collect(node string) (*Release, error) {
+	release := &Release{Node: node}
+	add := func(source, path string, mode os.FileMode) error {
+		data, err := ioutil.ReadFile(source)
+		if err != nil {
+			return err
+		}
+		release.Files = append(release.Files, File{Path: path, Source: source, Mode: mode, Sum: fmt.Sprintf("%x", sha256.Sum256(data))})
+		return nil
+	}
+	glob := func(pattern, dir string, mode os.FileMode) error {
+		files, err := filepath.Glob(filepath.Join(node, pattern))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := add(file, dir+filepath.Base(file), mode); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	units, err := filepath.Glob(filepath.Join(node, "configs", "*.service"))
+	if err != nil {
+		return nil, err
+	}
+	for _, unit := range units {
+		release.Units = append(release.Units, strings.TrimSuffix(filepath.Base(unit), ".service"))
+	}
+	steps := []struct {
+		pattern, dir string
+		mode         os.FileMode
+	}{
+		{"configs/*.service", "units/", 0644},
+		{"configs/*.json", "etc/", 0644},
+		{"configs/*.conf", "etc/", 0644},
+		{"pki/pki/private/*.key", "etc/keys/", 0600},
+		{"pki/pki/issued/*.crt", "etc/keys/", 0644},
+		{"pki/pki/ca.crt", "etc/keys/", 0644},
+		{"pki/pki/dh.pem", "etc/keys/", 0644},
+		{"pki/pki/crl.pem", "etc/keys/", 0644},
+		{"foreignKeys/*.crt", "etc/keys/", 0644},
+		{"foreignKeys/*.key", "etc/keys/", 0600},
+	}
+	for _, step := range steps {
+		if err := glob(step.pattern, step.dir, step.mode); err != nil {
+			return nil, err
+		}
+	}
+	// the ca key never leaves the development machine
+	files := release.Files[:0]
+	for _, file := range release.Files {
+		if file.Path != "etc/keys/ca.key" {
+			files = append(files, file)
+		}
+	}
+	release.Files = files
+
+	assets := filepath.Join(node, "assets")
+	err = filepath.Walk(assets, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(assets, file)
+		if err != nil {
+			return err
+		}
+		return add(file, "share/"+filepath.ToSlash(rel), 0644)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sort.Slice(release.Files, func(i, j int) bool { return release.Files[i].Path < release.Files[j].Path })
+	return release, nil
+}
+
+// AddBinaries adds binaries and all libraries of a susi build directory to the release
+func (release *Release) AddBinaries(dir string, binaries []string) error {
+	var files []File
+	for _, binary := range binaries {
+		files = append(files, File{Path: "bin/" + binary, Source: filepath.Join(dir, "bin", binary), Mode: 0755})
+	}
+	libs, err := filepath.Glob(filepath.Join(dir, "lib", "*.so*"))
+	if err != nil {
+		return err
+	}
+	for _, lib := range libs {
+		files = append(files, File{Path: "lib/" + filepath.Base(lib), Source: lib, Mode: 0755})
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file.Source)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%v is missing, build susi with susi-dev source build first", file.Source)
+		}
+		if err != nil {
+			return err
+		}
+		file.Sum = fmt.Sprintf("%x", sha256.Sum256(data))
+		release.Files = append(release.Files, file)
+	}
+	sort.Slice(release.Files, func(i, j int) bool { return release.Files[i].Path < release.Files[j].Path })
+	return nil
+}
+
+// Manifest returns the sums of all files in sha256sum format
+func (release *Release) Manifest() string {
+	manifest := ""
+	for _, file := range release.Files {
+		manifest += fmt.Sprintf("%v  %v\n", file.Sum, file.Path)
+	}
+	return manifest
+}
+
+// Sums returns the sums of all files by path
+func (release *Release) Sums() map[string]string {
+	sums := map[string]string{}
+	for _, file := range release.Files {
+		sums[file.Path] = file.Sum
+	}
+	return sums
+}
+
+// ParseManifest reads a manifest written by Manifest
+func ParseManifest(manifest string) map[string]string {
+	sums := map[string]string{}
+	for _, line := range strings.Split(manifest, "\n") {
+		parts := strings.SplitN(line, "  ", 2)
+		if len(parts) == 2 {
+			sums[parts[1]] = parts[0]
+		}
+	}
+	return sums
+}
+
+// WriteTar writes the release and its manifest as gzipped tar, all files are owned by root
+func (release *Release) WriteTar(w io.Writer) error {
+	return release.writeTar(w, "", nil)
+}
+
+// blob is a generated file written into an archive
+type blob struct {
+	path string
+	mode os.FileMode
+	data []byte
+}
+
+// writeTar writes the release, its manifest and extra files below prefix as gzipped tar
+func (release *Release) writeTar(w io.Writer, prefix string, extra []blob) error {
+	gz := gzip.NewWriter(w)
+	archive := tar.NewWriter(gz)
+	write := func(path string, mode os.FileMode, data []byte) error {
+		header := &tar.Header{Name: prefix + path, Mode: int64(mode), Size: int64(len(data)), Typeflag: tar.TypeReg}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := archive.Write(data)
+		return err
+	}
+	for _, file := range release.Files {
+		data, err := ioutil.ReadFile(file.Source)
+		if err != nil {
+			return err
+		}
+		if err := write(file.Path, file.Mode, data); err != nil {
+			return err
+		}
+	}
+	extra = append(extra, blob{ManifestFile, 0644, []byte(release.Manifest())})
+	for _, file := range extra {
+		if err := write(file.path, file.mode, file.data); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// unitOf returns the unit a file of the release belongs to, "" for files shared by all units
+func (release *Release) unitOf(path string) string {
+	name := filepath.Base(path)
+	for _, unit := range release.Units {
+		for _, own := range []string{"units/" + unit + ".service", "etc/" + unit + ".json", "etc/" + unit + ".conf", "etc/keys/" + unit + ".crt", "etc/keys/" + unit + ".key"} {
+			if path == own {
+				return unit
+			}
+		}
+	}
+	if strings.HasPrefix(path, "units/") {
+		return strings.TrimSuffix(name, ".service")
+	}
+	// binaries are named like the unit which runs them
+	if strings.HasPrefix(path, "bin/") {
+		for _, unit := range release.Units {
+			if name == unit {
+				return unit
+			}
+		}
+	}
+	return ""
+}
+
+// ChangedUnits returns the units which have to be restarted compared to a previous manifest.
+// A change of a shared file like an asset or a foreign key restarts all units.
+func (release *Release) ChangedUnits(previous map[string]string) []string {
+	changed := map[string]bool{}
+	shared := false
+	current := release.Sums()
+	mark := func(path string) {
+		if unit := release.unitOf(path); unit != "" {
+			changed[unit] = true
+		} else {
+			shared = true
+		}
+	}
+	for path, sum := range current {
+		if previous[path] != sum {
+			mark(path)
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			mark(path)
+		}
+	}
+	var units []string
+	for _, unit := range release.Units {
+		if shared || changed[unit] {
+			units = append(units, unit)
+		}
+	}
+	return units
+}
+
+func (release *Release) tarball() (*bytes.Buffer, error) {
+	buff := &bytes.Buffer{}
+	return buff, release.WriteTar(buff)
+}
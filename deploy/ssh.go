@@ -0,0 +1,168 @@
+package deploy
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/webvariants/susi-dev/executor"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// hostConfig is the ssh client configuration of a host, as ssh resolves it from ~/.ssh/config
+type hostConfig struct {
+	User          string
+	Hostname      string
+	Port          string
+	IdentityFiles []string
+	KnownHosts    []string
+	// ProxyJump is a comma separated list of jump hosts, empty connects directly
+	ProxyJump string
+}
+
+// Addr returns host:port of the ssh server
+func (config *hostConfig) Addr() string {
+	return net.JoinHostPort(config.Hostname, config.Port)
+}
+
+// splitTarget splits a target given as [user@]host[:port]
+func splitTarget(target string) (user, host, port string) {
+	host = target
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		user, host = host[:i], host[i+1:]
+	}
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host, port = h, p
+	}
+	return user, host, port
+}
+
+// resolve returns the configuration of a target, user and port of the target win over
+// ~/.ssh/config like on the ssh command line. Without ssh the defaults of ssh are used.
+func resolve(target, home string) (*hostConfig, error) {
+	user, host, port := splitTarget(target)
+	args := []string{"ssh", "-G"}
+	if user != "" {
+		args = append(args, "-l", user)
+	}
+	if port != "" {
+		args = append(args, "-p", port)
+	}
+	out, err := executor.Output(executor.Command{Args: append(args, host), Stdin: strings.NewReader("")})
+	if errors.Is(err, executor.ErrMissing) {
+		out, err = "", nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolving %v with ssh -G: %w", target, err)
+	}
+	config := parseHostConfig(out, home)
+	if config.User == "" {
+		config.User = os.Getenv("USER")
+		if user != "" {
+			config.User = user
+		}
+	}
+	if config.Hostname == "" {
+		config.Hostname = host
+	}
+	if config.Port == "" {
+		config.Port = "22"
+		if port != "" {
+			config.Port = port
+		}
+	}
+	if len(config.IdentityFiles) == 0 {
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			config.IdentityFiles = append(config.IdentityFiles, home+"/.ssh/"+name)
+		}
+	}
+	if len(config.KnownHosts) == 0 {
+		config.KnownHosts = []string{home + "/.ssh/known_hosts"}
+	}
+	return config, nil
+}
+
+// parseHostConfig parses the output of ssh -G, ~ and %d in file names are the home directory
+func parseHostConfig(out, home string) *hostConfig {
+	expand := func(file string) string {
+		if strings.HasPrefix(file, "~/") {
+			file = home + file[1:]
+		}
+		return strings.Replace(file, "%d", home, -1)
+	}
+	config := &hostConfig{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch strings.ToLower(fields[0]) {
+		case "user":
+			config.User = fields[1]
+		case "hostname":
+			config.Hostname = fields[1]
+		case "port":
+			config.Port = fields[1]
+		case "identityfile":
+			config.IdentityFiles = append(config.IdentityFiles, expand(fields[1]))
+		case "userknownhostsfile", "globalknownhostsfile":
+			for _, file := range fields[1:] {
+				config.KnownHosts = append(config.KnownHosts, expand(file))
+			}
+		case "proxyjump":
+			if fields[1] != "none" {
+				config.ProxyJump = fields[1]
+			}
+		}
+	}
+	return config
+}
+
+// hostKeys returns the host key check of the known_hosts files and the algorithms of the keys known for
+// the host. Without the algorithms the server is asked for the key Go prefers, which may not be known.
+func (config *hostConfig) hostKeys() (ssh.HostKeyCallback, []string, error) {
+	var files []string
+	for _, file := range config.KnownHosts {
+		if _, err := os.Stat(file); err == nil {
+			files = append(files, file)
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no known_hosts file, connect once with ssh to accept the host key of %v", config.Addr())
+	}
+	callback, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return callback, hostKeyAlgorithms(callback, config.Addr()), nil
+}
+
+// unknownKey is a key no known_hosts file holds, checking it reports the keys known for a host
+var unknownKey, _ = ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+
+// hostKeyAlgorithms returns the algorithms of the keys callback knows for addr, nil if it knows none
+func hostKeyAlgorithms(callback ssh.HostKeyCallback, addr string) []string {
+	var keyErr *knownhosts.KeyError
+	if err := callback(addr, &net.TCPAddr{IP: net.IPv4zero}, unknownKey); !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algorithms []string
+	seen := map[string]bool{}
+	for _, known := range keyErr.Want {
+		names := []string{known.Key.Type()}
+		if names[0] == ssh.KeyAlgoRSA {
+			names = []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+		}
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				algorithms = append(algorithms, name)
+			}
+		}
+	}
+	return algorithms
+}
@@ -0,0 +1,168 @@
+package deploy
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestSplitTarget(t *testing.T) {
+	for _, test := range []struct {
+		target, user, host, port string
+	}{
+		{"gw", "", "gw", ""},
+		{"pi@gw", "pi", "gw", ""},
+		{"pi@10.0.0.5:2222", "pi", "10.0.0.5", "2222"},
+		{"[::1]:22", "", "::1", "22"},
+		{"me@corp@gw", "me@corp", "gw", ""},
+	} {
+		user, host, port := splitTarget(test.target)
+		if user != test.user || host != test.host || port != test.port {
+			t.Errorf("splitTarget(%q) = %q %q %q, want %q %q %q", test.target, user, host, port, test.user, test.host, test.port)
+		}
+	}
+}
+
+func TestParseHostConfig(t *testing.T) {
+	out := `user pi
+hostname 10.0.0.5
+port 2222
+identityfile ~/.ssh/gw_key
+identityfile %d/.ssh/id_ed25519
+globalknownhostsfile /etc/ssh/ssh_known_hosts
+userknownhostsfile ~/.ssh/known_hosts /home/pi/.ssh/known_hosts2
+proxyjump admin@bastion:2200,inner
+hostkeyalgorithms ecdsa-sha2-nistp256,ssh-ed25519
+`
+	config := parseHostConfig(out, "/home/pi")
+	want := hostConfig{
+		User:          "pi",
+		Hostname:      "10.0.0.5",
+		Port:          "2222",
+		IdentityFiles: []string{"/home/pi/.ssh/gw_key", "/home/pi/.ssh/id_ed25519"},
+		KnownHosts:    []string{"/etc/ssh/ssh_known_hosts", "/home/pi/.ssh/known_hosts", "/home/pi/.ssh/known_hosts2"},
+		ProxyJump:     "admin@bastion:2200,inner",
+	}
+	if config.User != want.User || config.Hostname != want.Hostname || config.Port != want.Port || config.ProxyJump != want.ProxyJump ||
+		!equal(config.IdentityFiles, want.IdentityFiles) || !equal(config.KnownHosts, want.KnownHosts) {
+		t.Errorf("parsed %+v, want %+v", *config, want)
+	}
+	if config.Addr() != "10.0.0.5:2222" {
+		t.Errorf("address is %v", config.Addr())
+	}
+	if config := parseHostConfig("proxyjump none\n", "/home/pi"); config.ProxyJump != "" {
+		t.Errorf("proxyjump none is %q, want a direct connection", config.ProxyJump)
+	}
+}
+
+func newSigner(t *testing.T, key interface{}) ssh.Signer {
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestHostKeyAlgorithms(t *testing.T) {
+	_, ed25519Key, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Signer, rsaSigner := newSigner(t, ed25519Key), newSigner(t, rsaKey)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "known_hosts"),
+		knownhosts.Line([]string{"gw"}, ed25519Signer.PublicKey())+"\n"+
+			knownhosts.Line([]string{"[gw]:2222"}, rsaSigner.PublicKey())+"\n"+
+			knownhosts.Line([]string{"gw", "other"}, rsaSigner.PublicKey())+"\n")
+	callback, err := knownhosts.New(filepath.Join(dir, "known_hosts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		addr string
+		want []string
+	}{
+		{"gw:22", []string{ssh.KeyAlgoED25519, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}},
+		{"gw:2222", []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}},
+		{"unknown:22", nil},
+	} {
+		if algorithms := hostKeyAlgorithms(callback, test.addr); !equal(algorithms, test.want) {
+			t.Errorf("algorithms of %v are %v, want %v", test.addr, algorithms, test.want)
+		}
+	}
+}
+
+// TestConnectWithKnownEd25519Key connects to a server with an ecdsa and an ed25519 host key,
+// while known_hosts holds only the ed25519 key
+func TestConnectWithKnownEd25519Key(t *testing.T) {
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, ed25519Key, _ := ed25519.GenerateKey(rand.Reader)
+	_, userKey, _ := ed25519.GenerateKey(rand.Reader)
+	ed25519Signer := newSigner(t, ed25519Key)
+
+	server := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	server.AddHostKey(newSigner(t, ecdsaKey))
+	server.AddHostKey(ed25519Signer)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		if _, channels, requests, err := ssh.NewServerConn(conn, server); err == nil {
+			go ssh.DiscardRequests(requests)
+			for channel := range channels {
+				channel.Reject(ssh.Prohibited, "handshake only")
+			}
+		}
+	}()
+
+	dir := t.TempDir()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	config := &hostConfig{User: "pi", Hostname: host, Port: port,
+		IdentityFiles: []string{filepath.Join(dir, "id_ed25519")},
+		KnownHosts:    []string{filepath.Join(dir, "missing"), filepath.Join(dir, "known_hosts")},
+	}
+	block, err := ssh.MarshalPrivateKey(userKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, config.IdentityFiles[0], string(pem.EncodeToMemory(block)))
+	writeFile(t, config.KnownHosts[1], knownhosts.Line([]string{knownhosts.Normalize(config.Addr())}, ed25519Signer.PublicKey())+"\n")
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	client, err := connect(config, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+}
+
+func TestConnectWithoutKnownHosts(t *testing.T) {
+	config := &hostConfig{Hostname: "gw", Port: "22", KnownHosts: []string{filepath.Join(t.TempDir(), "known_hosts")}}
+	if _, err := connect(config, "/nonexistent"); err == nil || !strings.Contains(err.Error(), "connect once with ssh") {
+		t.Errorf("connecting without known_hosts returned %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package deploy
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/webvariants/susi-dev/executor"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Target runs commands on the machine a node is deployed to
+type Target interface {
+	// Run runs a command and returns its output
+	Run(command executor.Command) (string, error)
+	Close() error
+}
+
+// sshTarget runs commands over an ssh connection
+type sshTarget struct {
+	client *ssh.Client
+}
+
+// Dial connects to a target given as [user@]host[:port] with the settings ssh uses for it in
+// ~/.ssh/config, like HostName, Port, User, IdentityFile and ProxyJump. Keys are taken from the
+// ssh-agent and the identity files, the host key has to be in known_hosts.
+// On a dry run the commands are printed instead.
+func Dial(target string) (Target, error) {
+	if executor.IsDryRun() {
+		fmt.Printf("# commands run on %v\n", target)
+		return &LocalTarget{}, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	config, err := resolve(target, home)
+	if err != nil {
+		return nil, err
+	}
+	client, err := connect(config, home)
+	if err != nil {
+		return nil, err
+	}
+	return &sshTarget{client}, nil
+}
+
+// connect connects to a host, through its jump hosts if it has any
+func connect(config *hostConfig, home string) (*ssh.Client, error) {
+	hostKeys, algorithms, err := config.hostKeys()
+	if err != nil {
+		return nil, err
+	}
+	clientConfig := &ssh.ClientConfig{
+		User:              config.User,
+		Auth:              []ssh.AuthMethod{ssh.PublicKeysCallback(signers(config.IdentityFiles))},
+		HostKeyCallback:   hostKeys,
+		HostKeyAlgorithms: algorithms,
+	}
+	if config.ProxyJump == "" {
+		return ssh.Dial("tcp", config.Addr(), clientConfig)
+	}
+	// the last jump host is reached through the ones before it
+	hops := strings.Split(config.ProxyJump, ",")
+	jump, err := resolve(hops[len(hops)-1], home)
+	if err != nil {
+		return nil, err
+	}
+	if len(hops) > 1 {
+		jump.ProxyJump = strings.Join(hops[:len(hops)-1], ",")
+	}
+	proxy, err := connect(jump, home)
+	if err != nil {
+		return nil, fmt.Errorf("jump host %v: %w", hops[len(hops)-1], err)
+	}
+	conn, err := proxy.Dial("tcp", config.Addr())
+	if err != nil {
+		proxy.Close()
+		return nil, err
+	}
+	c, channels, requests, err := ssh.NewClientConn(conn, config.Addr(), clientConfig)
+	if err != nil {
+		proxy.Close()
+		return nil, err
+	}
+	client := ssh.NewClient(c, channels, requests)
+	go func() {
+		client.Wait()
+		proxy.Close()
+	}()
+	return client, nil
+}
+
+// signers returns the keys of the ssh-agent and the unencrypted identity files
+func signers(identityFiles []string) func() ([]ssh.Signer, error) {
+	return func() ([]ssh.Signer, error) {
+		var result []ssh.Signer
+		if socket := os.Getenv("SSH_AUTH_SOCK"); socket != "" {
+			if conn, err := net.Dial("unix", socket); err == nil {
+				if agentSigners, err := agent.NewClient(conn).Signers(); err == nil {
+					result = append(result, agentSigners...)
+				}
+			}
+		}
+		for _, file := range identityFiles {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			if signer, err := ssh.ParsePrivateKey(data); err == nil {
+				result = append(result, signer)
+			}
+		}
+		return result, nil
+	}
+}
+
+func (t *sshTarget) Run(command executor.Command) (string, error) {
+	session, err := t.client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+	var stdout, stderr bytes.Buffer
+	session.Stdin = command.Stdin
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Run(command.Line()); err != nil {
+		return stdout.String(), fmt.Errorf("%v: %v", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func (t *sshTarget) Close() error {
+	return t.client.Close()
+}
+
+// LocalTarget runs commands on this machine with the default executor, it stands in for a ssh server in tests
+type LocalTarget struct{}
+
+// Run runs command with the default executor
+func (t *LocalTarget) Run(command executor.Command) (string, error) {
+	if command.Stdin == nil {
+		// like a ssh session, local commands do not read the terminal
+		command.Stdin = strings.NewReader("")
+	}
+	return executor.Output(command)
+}
+
+// Close does nothing
+func (t *LocalTarget) Close() error {
+	return nil
+}
@@ -0,0 +1,187 @@
+// Package dns is a small resolver for the pod network. It answers A queries for the
+// node ids and FQDNs of a project and forwards all other queries to an upstream resolver.
+package dns
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	typeA   = 1
+	typeANY = 255
+	classIN = 1
+
+	rcodeFormatError    = 1
+	rcodeServerFailure  = 2
+	rcodeNameError      = 3
+	rcodeNotImplemented = 4
+
+	// ttl is short, the nodes of a project change while the pods run
+	ttl = 5
+	// maxMessage is large enough for EDNS answers of the upstream resolver
+	maxMessage = 4096
+)
+
+// Server answers the queries of the pods
+type Server struct {
+	// Names returns the current IPs by lower case name, it is called for every query
+	Names func() (map[string]string, error)
+	// Upstream is the host:port of the resolver for other names, empty answers them with NXDOMAIN
+	Upstream string
+}
+
+// Serve answers the queries arriving on conn until it is closed
+func (s *Server) Serve(conn net.PacketConn) error {
+	buffer := make([]byte, maxMessage)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return err
+		}
+		query := append([]byte(nil), buffer[:n]...)
+		go func() {
+			if response := s.answer(query); response != nil {
+				conn.WriteTo(response, addr)
+			}
+		}()
+	}
+}
+
+// answer returns the response to a query, nil drops it
+func (s *Server) answer(query []byte) []byte {
+	if len(query) < 12 || query[2]&0x80 != 0 {
+		return nil
+	}
+	if opcode := query[2] >> 3 & 0x0f; opcode != 0 {
+		return response(query, 12, rcodeNotImplemented, nil)
+	}
+	if binary.BigEndian.Uint16(query[4:6]) != 1 {
+		return response(query, 12, rcodeFormatError, nil)
+	}
+	name, end, ok := question(query)
+	if !ok {
+		return response(query, 12, rcodeFormatError, nil)
+	}
+	names, err := s.Names()
+	if err != nil {
+		return response(query, end, rcodeServerFailure, nil)
+	}
+	ip, known := names[name]
+	if !known {
+		if s.Upstream == "" {
+			return response(query, end, rcodeNameError, nil)
+		}
+		forwarded, err := forward(s.Upstream, query)
+		if err != nil {
+			return response(query, end, rcodeServerFailure, nil)
+		}
+		return forwarded
+	}
+	qtype := binary.BigEndian.Uint16(query[end-4 : end-2])
+	qclass := binary.BigEndian.Uint16(query[end-2 : end])
+	address := net.ParseIP(ip).To4()
+	if qclass != classIN || (qtype != typeA && qtype != typeANY) || address == nil {
+		// the name exists but has no records of this type
+		return response(query, end, 0, nil)
+	}
+	return response(query, end, 0, address)
+}
+
+// question returns the lower case name of the question and the offset behind it
+func question(query []byte) (string, int, bool) {
+	var labels []string
+	i := 12
+	for {
+		if i >= len(query) {
+			return "", 0, false
+		}
+		length := int(query[i])
+		i++
+		if length == 0 {
+			break
+		}
+		// questions are never compressed
+		if length&0xc0 != 0 || i+length > len(query) {
+			return "", 0, false
+		}
+		labels = append(labels, strings.ToLower(string(query[i:i+length])))
+		i += length
+	}
+	if i+4 > len(query) {
+		return "", 0, false
+	}
+	return strings.Join(labels, "."), i + 4, true
+}
+
+// response returns the response to the question in query[12:end] with one A record if address is set
+func response(query []byte, end int, rcode byte, address net.IP) []byte {
+	message := make([]byte, 12, end+16)
+	copy(message, query[:2])
+	// response, authoritative, recursion desired as asked, recursion available
+	message[2] = 0x84 | query[2]&0x01
+	message[3] = 0x80 | rcode
+	if end > 12 {
+		binary.BigEndian.PutUint16(message[4:6], 1)
+		message = append(message, query[12:end]...)
+	}
+	if address != nil {
+		binary.BigEndian.PutUint16(message[6:8], 1)
+		// the name is a pointer to the question at offset 12
+		message = append(message, 0xc0, 12, 0, typeA, 0, classIN)
+		message = binary.BigEndian.AppendUint32(message, ttl)
+		message = append(message, 0, 4)
+		message = append(message, address...)
+	}
+	return message
+}
+
+// forward sends a query to the upstream resolver and returns its response
+func forward(upstream string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", upstream, 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, maxMessage)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return buffer[:n], nil
+}
+
+// SystemResolver returns the first nameserver of /etc/resolv.conf as host:port, except the skipped ones
+func SystemResolver(skip ...string) string {
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "nameserver" || contains(skip, fields[1]) {
+			continue
+		}
+		return net.JoinHostPort(fields[1], "53")
+	}
+	return ""
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
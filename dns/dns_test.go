@@ -0,0 +1,173 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// query builds a standard query with recursion desired for one question
+func query(id uint16, name string, qtype uint16) []byte {
+	message := []byte{byte(id >> 8), byte(id), 0x01, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	for _, label := range strings.Split(name, ".") {
+		message = append(message, byte(len(label)))
+		message = append(message, label...)
+	}
+	message = append(message, 0)
+	message = binary.BigEndian.AppendUint16(message, qtype)
+	return binary.BigEndian.AppendUint16(message, classIN)
+}
+
+// parsed is the part of a response the tests check
+type parsed struct {
+	id      uint16
+	rcode   byte
+	answers int
+	ip      string
+}
+
+func parse(t *testing.T, response []byte) parsed {
+	t.Helper()
+	if len(response) < 12 || response[2]&0x80 == 0 {
+		t.Fatalf("no response: %v", response)
+	}
+	p := parsed{
+		id:      binary.BigEndian.Uint16(response[0:2]),
+		rcode:   response[3] & 0x0f,
+		answers: int(binary.BigEndian.Uint16(response[6:8])),
+	}
+	if p.answers > 0 {
+		record := response[len(response)-16:]
+		if record[0] != 0xc0 || record[1] != 12 || binary.BigEndian.Uint16(record[2:4]) != typeA || binary.BigEndian.Uint16(record[10:12]) != 4 {
+			t.Fatalf("malformed A record: %v", record)
+		}
+		p.ip = net.IP(record[12:16]).String()
+	}
+	return p
+}
+
+func names() (map[string]string, error) {
+	return map[string]string{"gw": "172.16.28.2", "gw.example.com": "172.16.28.2", "sensor": "172.16.28.3", "v6": "fd00::1"}, nil
+}
+
+func TestAnswer(t *testing.T) {
+	server := &Server{Names: names}
+	for _, test := range []struct {
+		name  string
+		query []byte
+		want  parsed
+	}{
+		{"node", query(1, "gw", typeA), parsed{1, 0, 1, "172.16.28.2"}},
+		{"fqdn", query(2, "gw.example.com", typeA), parsed{2, 0, 1, "172.16.28.2"}},
+		{"case", query(3, "SeNsOr", typeA), parsed{3, 0, 1, "172.16.28.3"}},
+		{"any", query(4, "sensor", typeANY), parsed{4, 0, 1, "172.16.28.3"}},
+		{"aaaa", query(5, "gw", 28), parsed{5, 0, 0, ""}},
+		{"no ipv4", query(6, "v6", typeA), parsed{6, 0, 0, ""}},
+		{"unknown", query(7, "example.org", typeA), parsed{7, rcodeNameError, 0, ""}},
+		{"inverse query", func() []byte { q := query(8, "gw", typeA); q[2] |= 1 << 3; return q }(), parsed{8, rcodeNotImplemented, 0, ""}},
+		{"two questions", func() []byte { q := query(9, "gw", typeA); q[5] = 2; return q }(), parsed{9, rcodeFormatError, 0, ""}},
+		{"truncated", query(10, "gw", typeA)[:16], parsed{10, rcodeFormatError, 0, ""}},
+		{"compressed", append(query(11, "gw", typeA)[:12], 0xc0, 12, 0, 1, 0, 1), parsed{11, rcodeFormatError, 0, ""}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parse(t, server.answer(test.query)); got != test.want {
+				t.Errorf("answered %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAnswerDrops(t *testing.T) {
+	server := &Server{Names: names}
+	response := query(1, "gw", typeA)
+	response[2] |= 0x80
+	for _, message := range [][]byte{nil, {1, 2, 3}, response} {
+		if answer := server.answer(message); answer != nil {
+			t.Errorf("answered %v to %v", answer, message)
+		}
+	}
+}
+
+func TestAnswerNamesFailure(t *testing.T) {
+	server := &Server{Names: func() (map[string]string, error) { return nil, errors.New("no project") }}
+	if got := parse(t, server.answer(query(1, "gw", typeA))); got.rcode != rcodeServerFailure {
+		t.Errorf("answered %+v, want a server failure", got)
+	}
+}
+
+// listen answers every query on a local udp socket with handler
+func listen(t *testing.T, handler func([]byte) []byte) (net.PacketConn, string) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buffer := make([]byte, maxMessage)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			if response := handler(buffer[:n]); response != nil {
+				conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return conn, conn.LocalAddr().String()
+}
+
+func exchange(t *testing.T, addr string, message []byte) []byte {
+	t.Helper()
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Write(message); err != nil {
+		t.Fatal(err)
+	}
+	buffer := make([]byte, maxMessage)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer[:n]
+}
+
+func TestServeForwardsUnknownNames(t *testing.T) {
+	// the upstream resolver knows example.org
+	_, upstream := listen(t, func(q []byte) []byte {
+		return response(q, len(q), 0, net.ParseIP("93.184.216.34").To4())
+	})
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go (&Server{Names: names, Upstream: upstream}).Serve(conn)
+
+	for _, test := range []struct {
+		name, ip string
+	}{
+		{"gw", "172.16.28.2"},
+		{"example.org", "93.184.216.34"},
+	} {
+		if got := parse(t, exchange(t, conn.LocalAddr().String(), query(42, test.name, typeA))); got.id != 42 || got.ip != test.ip {
+			t.Errorf("%v resolved to %+v, want %v", test.name, got, test.ip)
+		}
+	}
+}
+
+func TestForwardFailure(t *testing.T) {
+	// the upstream resolver never answers
+	_, upstream := listen(t, func([]byte) []byte { return nil })
+	server := &Server{Names: names, Upstream: upstream}
+	if got := parse(t, server.answer(query(1, "example.org", typeA))); got.rcode != rcodeServerFailure {
+		t.Errorf("answered %+v, want a server failure", got)
+	}
+}
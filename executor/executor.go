@@ -0,0 +1,224 @@
+// Package executor runs the commands and generated scripts of all packages.
+// Replacing Default prints the commands instead (dry run) or records them.
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Command is a program or a bash script to run
+type Command struct {
+	// Args is the program and its arguments, it is ignored if Script is set
+	Args []string
+	// Script is run by /bin/bash
+	Script string
+	// Sudo runs the command as root
+	Sudo bool
+	// Stdin is passed to the command, nil attaches the terminal
+	Stdin io.Reader
+}
+
+// Script returns a command running script
+func Script(script string) Command {
+	return Command{Script: script}
+}
+
+// SudoScript returns a command running script as root
+func SudoScript(script string) Command {
+	return Command{Script: script, Sudo: true}
+}
+
+// Program returns a command running a program without shell
+func Program(args ...string) Command {
+	return Command{Args: args}
+}
+
+// argv returns the program and arguments executed for the command
+func (command Command) argv() []string {
+	args := command.Args
+	if command.Script != "" {
+		args = []string{"/bin/bash", "-c", command.Script}
+	}
+	if command.Sudo {
+		args = append([]string{"sudo"}, args...)
+	}
+	return args
+}
+
+// Line returns the command as a single shell command line, like ssh expects it
+func (command Command) Line() string {
+	args := command.argv()
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = Quote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// String shows the command the way it would be typed into a shell, scripts are shown as here documents
+func (command Command) String() string {
+	var line string
+	if command.Script != "" {
+		line = "/bin/bash <<'EOF'\n" + strings.Trim(command.Script, "\n") + "\nEOF"
+		if command.Sudo {
+			line = "sudo " + line
+		}
+	} else {
+		line = command.Line()
+	}
+	return line
+}
+
+// Quote quotes s for bash if necessary
+func Quote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:@,+%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// ErrMissing is returned when a program a command needs is not installed
+var ErrMissing = errors.New("missing dependency")
+
+// notFound is the exit status of bash when a command of a script is not found
+const notFound = 127
+
+// Executor runs commands
+type Executor interface {
+	// Run runs a command with its output on the terminal
+	Run(command Command) error
+	// Output runs a command and returns its standard output, errors go to the terminal
+	Output(command Command) (string, error)
+}
+
+// Default is used by Run and Output
+var Default Executor = &Local{}
+
+// Run runs a command with the default executor
+func Run(command Command) error {
+	return Default.Run(command)
+}
+
+// Output runs a command with the default executor and returns its output
+func Output(command Command) (string, error) {
+	return Default.Output(command)
+}
+
+// IsDryRun tells whether commands are only printed
+func IsDryRun() bool {
+	_, ok := Default.(*DryRun)
+	return ok
+}
+
+// Local runs commands on this machine
+type Local struct{}
+
+func (l *Local) command(command Command) *exec.Cmd {
+	args := command.argv()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = command.Stdin
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+func (l *Local) Run(command Command) error {
+	cmd := l.command(command)
+	cmd.Stdout = os.Stdout
+	return missing(cmd.Run())
+}
+
+func (l *Local) Output(command Command) (string, error) {
+	cmd := l.command(command)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := cmd.Run()
+	return out.String(), missing(err)
+}
+
+// missing marks errors caused by programs which are not installed with ErrMissing
+func missing(err error) error {
+	var exit *exec.ExitError
+	switch {
+	case errors.Is(err, exec.ErrNotFound):
+		return fmt.Errorf("%w: %v", ErrMissing, err)
+	case errors.As(err, &exit) && exit.ExitCode() == notFound:
+		return fmt.Errorf("%w: a command was not found, see the output above", ErrMissing)
+	}
+	return err
+}
+
+// DryRun prints commands instead of running them, the content of stdin is never printed
+type DryRun struct {
+	Out io.Writer
+}
+
+func (d *DryRun) Run(command Command) error {
+	out := d.Out
+	if out == nil {
+		out = os.Stdout
+	}
+	line := command.String()
+	if command.Stdin != nil {
+		n, err := io.Copy(ioutil.Discard, command.Stdin)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			line = fmt.Sprintf("# %v bytes on stdin\n%v", n, line)
+		}
+	}
+	_, err := fmt.Fprintln(out, line)
+	return err
+}
+
+// Output prints the command and returns no output
+func (d *DryRun) Output(command Command) (string, error) {
+	return "", d.Run(command)
+}
+
+// Recorder records commands instead of running them, for tests of the command generation
+type Recorder struct {
+	mutex    sync.Mutex
+	Commands []Command
+	// Respond returns the output of a command, nil returns no output
+	Respond func(command Command) (string, error)
+}
+
+func (r *Recorder) Run(command Command) error {
+	_, err := r.Output(command)
+	return err
+}
+
+func (r *Recorder) Output(command Command) (string, error) {
+	r.mutex.Lock()
+	r.Commands = append(r.Commands, command)
+	r.mutex.Unlock()
+	if r.Respond == nil {
+		return "", nil
+	}
+	return r.Respond(command)
+}
+
+// Scripts returns the scripts and command lines of all recorded commands
+func (r *Recorder) Scripts() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	scripts := make([]string, 0, len(r.Commands))
+	for _, command := range r.Commands {
+		scripts = append(scripts, command.String())
+	}
+	return scripts
+}
@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const passphrase = "correct horse battery staple"
+
+func TestDryRunHidesStdin(t *testing.T) {
+	var out bytes.Buffer
+	dryRun := &DryRun{Out: &out}
+	command := Program("gpg", "--passphrase-fd", "0", "--detach-sign", "image.aci")
+	command.Stdin = strings.NewReader(passphrase + "\n")
+	if err := dryRun.Run(command); err != nil {
+		t.Fatal(err)
+	}
+	printed := out.String()
+	if strings.Contains(printed, passphrase) {
+		t.Errorf("the dry run printed the passphrase:\n%v", printed)
+	}
+	want := "# 29 bytes on stdin\ngpg --passphrase-fd 0 --detach-sign image.aci\n"
+	if printed != want {
+		t.Errorf("the dry run printed\n%v\nwant\n%v", printed, want)
+	}
+}
+
+func TestDryRunShowsScripts(t *testing.T) {
+	var out bytes.Buffer
+	if err := (&DryRun{Out: &out}).Run(SudoScript("set -e\nmkdir -p 'a b'\n")); err != nil {
+		t.Fatal(err)
+	}
+	want := "sudo /bin/bash <<'EOF'\nset -e\nmkdir -p 'a b'\nEOF\n"
+	if out.String() != want {
+		t.Errorf("the dry run printed\n%v\nwant\n%v", out.String(), want)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := &Recorder{Respond: func(command Command) (string, error) {
+		return "uuid\n", nil
+	}}
+	previous := Default
+	Default = recorder
+	defer func() { Default = previous }()
+
+	if err := Run(Script("echo hello")); err != nil {
+		t.Fatal(err)
+	}
+	command := Program("gpg", "--passphrase-fd", "0")
+	command.Stdin = strings.NewReader(passphrase)
+	out, err := Output(command)
+	if err != nil || out != "uuid\n" {
+		t.Errorf("Output returned %q, %v, want the response of Respond", out, err)
+	}
+	scripts := recorder.Scripts()
+	want := []string{"/bin/bash <<'EOF'\necho hello\nEOF", "gpg --passphrase-fd 0"}
+	if strings.Join(scripts, "\n") != strings.Join(want, "\n") {
+		t.Errorf("recorded %q, want %q", scripts, want)
+	}
+	for _, script := range scripts {
+		if strings.Contains(script, passphrase) {
+			t.Errorf("the passphrase is part of the recorded script %q", script)
+		}
+	}
+	stdin, err := ioutil.ReadAll(recorder.Commands[1].Stdin)
+	if err != nil || string(stdin) != passphrase {
+		t.Errorf("stdin of the recorded command is %q, %v", stdin, err)
+	}
+}
+
+func TestLine(t *testing.T) {
+	command := SudoScript("echo 'it works'")
+	want := `sudo /bin/bash -c 'echo '\''it works'\'''`
+	if line := command.Line(); line != want {
+		t.Errorf("Line is %v, want %v", line, want)
+	}
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/webvariants/susi-dev/arch"
+	"github.com/webvariants/susi-dev/components"
+	"github.com/webvariants/susi-dev/executor"
+	"github.com/webvariants/susi-dev/project"
+)
+
+// blueprint describes the nodes of a fleet, strings are templates of the variables of a node
+type blueprint struct {
+	// Name is the node id, it is required unless the inventory has a name column
+	Name string `yaml:"name"`
+	// Fqdn defaults to the node id
+	Fqdn       string   `yaml:"fqdn"`
+	Arch       string   `yaml:"arch"`
+	Components []string `yaml:"components"`
+	// ConnectTo is the node the susi-cluster of every node connects to, like a cloud node
+	ConnectTo string              `yaml:"connect-to"`
+	Topics    map[string][]string `yaml:"topics"`
+	// Config maps components to parameters or config paths and their values
+	Config map[string]map[string]interface{} `yaml:"config"`
+	// Assets maps files in the node assets to files relative to the blueprint
+	Assets map[string]string `yaml:"assets"`
+
+	dir string
+}
+
+// fleetNode is a node of a fleet with its rendered config values
+type fleetNode struct {
+	id, fqdn, ip string
+	config       map[string]map[string]interface{}
+}
+
+// loadBlueprint reads a yaml (or json) blueprint
+func loadBlueprint(file string) (*blueprint, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	b := &blueprint{dir: filepath.Dir(file)}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(b); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	for list := range b.Topics {
+		if !contains(components.TopicLists, list) {
+			return nil, fmt.Errorf("%v: no such topic list: %v, use one of %v", file, list, strings.Join(components.TopicLists, ", "))
+		}
+	}
+	return b, nil
+}
+
+// loadInventory reads a csv file with a header row, every other row is a node and the columns are its variables
+func loadInventory(file string) ([]map[string]string, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	reader := csv.NewReader(in)
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%v: there is no node below the header row", file)
+	}
+	var rows []map[string]string
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, column := range records[0] {
+			row[strings.TrimSpace(column)] = record[i]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// render executes a template with the variables of a node
+func render(text string, vars map[string]string) (string, error) {
+	tmpl, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	buff := bytes.Buffer{}
+	if err := tmpl.Execute(&buff, vars); err != nil {
+		return "", err
+	}
+	return buff.String(), nil
+}
+
+// renderValue substitutes the variables in the strings of a config value,
+// a substituted string is typed like a yaml scalar, so "{{.baud}}" can become a number
+func renderValue(value interface{}, vars map[string]string) (interface{}, error) {
+	switch value := value.(type) {
+	case string:
+		if !strings.Contains(value, "{{") {
+			return value, nil
+		}
+		text, err := render(value, vars)
+		if err != nil {
+			return nil, err
+		}
+		var typed interface{}
+		if err := yaml.Unmarshal([]byte(text), &typed); err != nil || typed == nil {
+			return text, nil
+		}
+		switch typed.(type) {
+		case int, float64, bool:
+			return typed, nil
+		}
+		return text, nil
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, v := range value {
+			rendered, err := renderValue(v, vars)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = rendered
+		}
+		return list, nil
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, v := range value {
+			rendered, err := renderValue(v, vars)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = rendered
+		}
+		return m, nil
+	}
+	return value, nil
+}
+
+// fleetNodes returns the nodes of a fleet, rows are the rows of the inventory or count empty ones
+func (b *blueprint) fleetNodes(myProject *project.Project, rows []map[string]string) ([]*fleetNode, error) {
+	var nodes []*fleetNode
+	seen := map[string]bool{}
+	for i, row := range rows {
+		vars := map[string]string{"Index": strconv.Itoa(i + 1)}
+		for k, v := range row {
+			vars[k] = v
+		}
+		node := &fleetNode{id: row["name"], fqdn: row["fqdn"], ip: row["ip"], config: map[string]map[string]interface{}{}}
+		var err error
+		if node.id == "" {
+			if b.Name == "" {
+				return nil, usagef("the blueprint needs a name like gw-{{.Index}} unless the inventory has a name column")
+			}
+			if node.id, err = render(b.Name, vars); err != nil {
+				return nil, fmt.Errorf("name of node %v: %w", i+1, err)
+			}
+		}
+		vars["Name"] = node.id
+		if node.fqdn == "" && b.Fqdn != "" {
+			if node.fqdn, err = render(b.Fqdn, vars); err != nil {
+				return nil, fmt.Errorf("fqdn of %v: %w", node.id, err)
+			}
+		}
+		switch {
+		case !validNodeID.MatchString(node.id):
+			return nil, usagef("invalid node id: %v, use letters, digits, '.', '_' and '-'", node.id)
+		case seen[node.id] || myProject.Nodes[node.id] != nil:
+			return nil, usagef("%v exists already", node.id)
+		}
+		if err := checkFree(node.id); err != nil {
+			return nil, err
+		}
+		// all templates are rendered before the first node is created
+		for component, paths := range b.Config {
+			node.config[component] = map[string]interface{}{}
+			for path, value := range paths {
+				if node.config[component][path], err = renderValue(value, vars); err != nil {
+					return nil, fmt.Errorf("%v: %v %v: %w", node.id, component, path, err)
+				}
+			}
+		}
+		seen[node.id] = true
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+// check fails before any node is created if the blueprint refers to unknown things
+func (b *blueprint) check(myProject *project.Project) error {
+	if b.Arch != "" {
+		if _, err := arch.Lookup(b.Arch); err != nil {
+			return err
+		}
+	}
+	for _, component := range b.Components {
+		if _, err := components.Lookup(component); err != nil {
+			return err
+		}
+	}
+	for component := range b.Config {
+		if !contains(b.Components, component) {
+			return fmt.Errorf("the blueprint configures %v, but does not add it", component)
+		}
+		if _, err := components.ConfigPath("", component); err != nil {
+			return err
+		}
+	}
+	if b.ConnectTo != "" {
+		if _, err := myProject.Node(b.ConnectTo); err != nil {
+			return err
+		}
+	}
+	for _, src := range b.Assets {
+		if _, err := os.Stat(filepath.Join(b.dir, src)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createFleet creates a node for every row with the existing create, add and connect
+func createFleet(myProject *project.Project, file string, count int, inventory string) error {
+	b, err := loadBlueprint(file)
+	if err != nil {
+		return err
+	}
+	var rows []map[string]string
+	switch {
+	case count > 0 && inventory != "":
+		return usagef("use either --count or --from")
+	case count > 0:
+		rows = make([]map[string]string, count)
+	case inventory != "":
+		if rows, err = loadInventory(inventory); err != nil {
+			return err
+		}
+	default:
+		return usagef("tell how many nodes to create with --count $n or --from $inventory")
+	}
+	if err := b.check(myProject); err != nil {
+		return err
+	}
+	nodes, err := b.fleetNodes(myProject, rows)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if planned("create %v with %v, connect it to %v", n.id, orNone(b.Components), orNone(strings.Fields(b.ConnectTo))) {
+			continue
+		}
+		if err := b.createNode(myProject, n); err != nil {
+			return fmt.Errorf("%v: %w", n.id, err)
+		}
+		fmt.Printf("created %v (%v)\n", n.id, myProject.Nodes[n.id].IP)
+	}
+	return nil
+}
+
+// createNode creates one node of the fleet
+func (b *blueprint) createNode(myProject *project.Project, n *fleetNode) error {
+	if err := create(myProject, n.id, n.fqdn, n.ip); err != nil {
+		return err
+	}
+	node := myProject.Nodes[n.id]
+	if b.Arch != arch.Default {
+		node.Arch = b.Arch
+	}
+	for _, component := range b.Components {
+		if err := addComponent(myProject, node, component, "", nil); err != nil {
+			return err
+		}
+	}
+	if b.ConnectTo != "" {
+		if err := connect(myProject, n.id, b.ConnectTo, b.Topics); err != nil {
+			return err
+		}
+	}
+	var configured []string
+	for component := range n.config {
+		configured = append(configured, component)
+	}
+	sort.Strings(configured)
+	for _, component := range configured {
+		paths := n.config[component]
+		for _, path := range sortedPaths(paths) {
+			if err := components.Set(n.id, component, components.Setting{Name: path, Value: paths[path]}); err != nil {
+				return fmt.Errorf("%v %v: %w", component, path, err)
+			}
+		}
+	}
+	for dst, src := range b.Assets {
+		file := filepath.Join(n.id, "assets", dst)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+		if err := executor.Run(executor.Program("cp", "-f", filepath.Join(b.dir, src), file)); err != nil {
+			return fmt.Errorf("copying %v: %w", src, err)
+		}
+	}
+	saveProject(myProject)
+	return nil
+}
+
+// sortedPaths sorts the config paths, so ports[0] is set before ports[1]
+func sortedPaths(paths map[string]interface{}) []string {
+	keys := make([]string, 0, len(paths))
+	for path := range paths {
+		keys = append(keys, path)
+	}
+	sort.Strings(keys)
+	return keys
+}
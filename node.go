@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/webvariants/susi-dev/components"
+	"github.com/webvariants/susi-dev/executor"
+	"github.com/webvariants/susi-dev/project"
+)
+
+// checkFree fails if the directory of a new node exists already
+func checkFree(nodeID string) error {
+	if _, err := os.Stat(nodeID); err == nil {
+		return usagef("the directory %v exists already", nodeID)
+	}
+	return nil
+}
+
+// connectedTo returns the ids of the nodes with a connection to nodeID
+func connectedTo(myProject *project.Project, nodeID string) []string {
+	var ids []string
+	for _, id := range myProject.IDs() {
+		if contains(myProject.Nodes[id].Connections, nodeID) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// deleteNode stops a node, revokes its certificates in the pki of its peers,
+// disconnects the nodes connected to it and removes its directory and its IP
+func deleteNode(myProject *project.Project, nodeID string) error {
+	node := loadNode(myProject, nodeID)
+	incoming := connectedTo(myProject, nodeID)
+	if planned("delete %v: stop its pod, revoke its certificates in the pki of %v, disconnect %v, remove the directory %v and release %v",
+		nodeID, orNone(node.Connections), orNone(incoming), nodeID, node.IP) {
+		return nil
+	}
+	if node.State.PodID != "" {
+		if err := stop(myProject, nodeID); err != nil {
+			return err
+		}
+	}
+	for _, peer := range node.Connections {
+		if err := components.Disconnect(nodeID, peer); err != nil {
+			return fmt.Errorf("disconnecting %v from %v: %w", nodeID, peer, err)
+		}
+	}
+	for _, id := range incoming {
+		if err := components.Disconnect(id, nodeID); err != nil {
+			return fmt.Errorf("disconnecting %v from %v: %w", id, nodeID, err)
+		}
+		myProject.Nodes[id].RemoveConnection(nodeID)
+	}
+	if err := os.RemoveAll(nodeID); err != nil {
+		return err
+	}
+	myProject.Delete(nodeID)
+	saveProject(myProject)
+	if len(incoming) > 0 {
+		fmt.Printf("rebuild and deploy %v, they were connected to %v\n", orNone(incoming), nodeID)
+	}
+	return nil
+}
+
+// renameNode moves a node to a new id, its peers issue certificates for the new id
+// and the nodes connected to it refer to the new id
+func renameNode(myProject *project.Project, oldID, newID string) error {
+	node := loadNode(myProject, oldID)
+	if node.State.PodID != "" {
+		return fmt.Errorf("%v is running, stop it before renaming it", oldID)
+	}
+	if err := checkFree(newID); err != nil {
+		return err
+	}
+	incoming := connectedTo(myProject, oldID)
+	if planned("rename %v to %v: move its directory, reissue its certificates in the pki of %v, update the cluster configs and foreignKeys of %v",
+		oldID, newID, orNone(node.Connections), orNone(incoming)) {
+		return nil
+	}
+	if err := os.Rename(oldID, newID); err != nil {
+		return err
+	}
+	myProject.Delete(oldID)
+	node.ID = newID
+	if node.Fqdn == oldID {
+		node.Fqdn = newID
+	}
+	myProject.Set(node)
+	for _, peer := range node.Connections {
+		if err := components.RenameNode(newID, oldID, peer, peerAddress(myProject, peer)); err != nil {
+			return fmt.Errorf("reconnecting %v to %v: %w", newID, peer, err)
+		}
+	}
+	for _, id := range incoming {
+		if err := components.RenamePeer(id, oldID, newID, node.Fqdn); err != nil {
+			return fmt.Errorf("updating %v: %w", id, err)
+		}
+		other := myProject.Nodes[id]
+		other.RemoveConnection(oldID)
+		other.AddConnection(newID)
+	}
+	saveProject(myProject)
+	fmt.Printf("rebuild and deploy %v to update the hosts files and configs\n", orNone(append([]string{newID}, incoming...)))
+	return nil
+}
+
+// cloneNode creates a node with a fresh pki, the components, configs, assets and connections of another node
+func cloneNode(myProject *project.Project, srcID, dstID string) error {
+	src := loadNode(myProject, srcID)
+	if err := checkFree(dstID); err != nil {
+		return err
+	}
+	if planned("clone %v to %v: create %v, add %v, copy the configs and assets and connect it to %v",
+		srcID, dstID, dstID, orNone(src.Components), orNone(src.Connections)) {
+		return nil
+	}
+	if err := create(myProject, dstID, *fqdn, *staticIP); err != nil {
+		return err
+	}
+	dst := myProject.Nodes[dstID]
+	dst.Arch = src.Arch
+	empty := ""
+	for _, component := range src.Components {
+		if err := components.Add(dstID, component, &empty, nil, nil); err != nil {
+			return err
+		}
+		dst.AddComponent(component)
+	}
+	// the configs and assets of the source replace the scaffolds of the components
+	for _, dir := range []string{"configs", "assets"} {
+		if err := executor.Run(executor.Program("cp", "-a", srcID+"/"+dir+"/.", dstID+"/"+dir+"/")); err != nil {
+			return fmt.Errorf("copying the %v of %v: %w", dir, srcID, err)
+		}
+	}
+	for _, peer := range src.Connections {
+		if err := components.Connect(dstID, peer, peerAddress(myProject, peer), nil); err != nil {
+			return fmt.Errorf("connecting %v to %v: %w", dstID, peer, err)
+		}
+		dst.AddConnection(peer)
+	}
+	saveProject(myProject)
+	return nil
+}
@@ -0,0 +1,190 @@
+package pki
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	indexTime = "060102150405Z"
+	crlValid  = 180 * 24 * time.Hour
+)
+
+// reasonCodes maps the openssl reason names to RFC 5280 reason codes
+var reasonCodes = map[string]int{
+	"unspecified":          0,
+	"keyCompromise":        1,
+	"CACompromise":         2,
+	"affiliationChanged":   3,
+	"superseded":           4,
+	"cessationOfOperation": 5,
+}
+
+// Revoke revokes an issued certificate and regenerates the certificate revocation list
+func Revoke(directory, name string) error {
+	return revoke(directory, name, "")
+}
+
+func revoke(directory, name, reason string) error {
+	crtFile, keyFile := pair(directory, name)
+	crt, err := LoadCertificate(crtFile)
+	if err != nil {
+		return err
+	}
+	if err := markRevoked(directory, crt, reason); err != nil {
+		return err
+	}
+	// move the pair away like easy-rsa does, so the name can be issued again
+	if err := archive(directory, name, crt); err != nil {
+		return err
+	}
+	for _, file := range []string{crtFile, keyFile} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return GenerateCRL(directory)
+}
+
+// markRevoked records a certificate as revoked in index.txt
+func markRevoked(directory string, crt *x509.Certificate, reason string) error {
+	serial := fmt.Sprintf("%X", crt.SerialNumber)
+	name := crt.Subject.CommonName
+	indexFile := filepath.Join(directory, "pki", "index.txt")
+	data, err := ioutil.ReadFile(indexFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	revokedAt := time.Now().UTC().Format(indexTime)
+	if reason != "" {
+		revokedAt += "," + reason
+	}
+	revoked := fmt.Sprintf("R\t%v\t%v\t%v\tunknown\t/CN=%v", crt.NotAfter.UTC().Format(indexTime), revokedAt, serial, name)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	found := false
+	for i, line := range lines {
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 && strings.EqualFold(fields[3], serial) {
+			if fields[0] == "R" {
+				return fmt.Errorf("%v is already revoked", name)
+			}
+			lines[i] = revoked
+			found = true
+		}
+	}
+	if !found {
+		lines = append(lines, revoked)
+	}
+	return ioutil.WriteFile(indexFile, []byte(strings.TrimPrefix(strings.Join(lines, "\n"), "\n")+"\n"), 0644)
+}
+
+// archive copies the pair of name into the revoked directories, named by the serial of crt
+func archive(directory, name string, crt *x509.Certificate) error {
+	serial := fmt.Sprintf("%X", crt.SerialNumber)
+	crtFile, keyFile := pair(directory, name)
+	for dir, files := range map[string][2]string{
+		"certs_by_serial":   {crtFile, serial + ".crt"},
+		"private_by_serial": {keyFile, serial + ".key"},
+	} {
+		data, err := ioutil.ReadFile(files[0])
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(directory, "pki", "revoked", dir)
+		if err := os.MkdirAll(target, 0700); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(target, files[1]), data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenerateCRL writes the certificate revocation list of the pki to pki/crl.pem
+func GenerateCRL(directory string) error {
+	ca, caKey, err := loadCA(directory)
+	if err != nil {
+		return err
+	}
+	entries, err := revokedEntries(directory)
+	if err != nil {
+		return err
+	}
+	number, err := nextCRLNumber(directory)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	template := &x509.RevocationList{
+		Number:                    number,
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(crlValid),
+		RevokedCertificateEntries: entries,
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca, caKey)
+	if err != nil {
+		return err
+	}
+	return writePEM(filepath.Join(directory, "pki", "crl.pem"), "X509 CRL", der, 0644)
+}
+
+// revokedEntries reads the revoked certificates from index.txt
+func revokedEntries(directory string) ([]x509.RevocationListEntry, error) {
+	data, err := ioutil.ReadFile(filepath.Join(directory, "pki", "index.txt"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []x509.RevocationListEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 6 || fields[0] != "R" {
+			continue
+		}
+		serial, ok := new(big.Int).SetString(fields[3], 16)
+		if !ok {
+			return nil, fmt.Errorf("index.txt: bad serial %v", fields[3])
+		}
+		// the revocation date may be followed by a reason
+		date := strings.Split(fields[2], ",")
+		revokedAt, err := time.Parse(indexTime, date[0])
+		if err != nil {
+			return nil, fmt.Errorf("index.txt: %v", err)
+		}
+		entry := x509.RevocationListEntry{SerialNumber: serial, RevocationTime: revokedAt}
+		if len(date) > 1 {
+			entry.ReasonCode = reasonCodes[date[1]]
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// nextCRLNumber increments the crlnumber file, which openssl also keeps as hex
+func nextCRLNumber(directory string) (*big.Int, error) {
+	file := filepath.Join(directory, "pki", "crlnumber")
+	number := big.NewInt(1)
+	if data, err := ioutil.ReadFile(file); err == nil {
+		if n, ok := new(big.Int).SetString(strings.TrimSpace(string(data)), 16); ok {
+			number = n
+		}
+	}
+	next := new(big.Int).Add(number, big.NewInt(1))
+	if err := ioutil.WriteFile(file, []byte(fmt.Sprintf("%02X\n", next)), 0644); err != nil {
+		return nil, err
+	}
+	return number, nil
+}
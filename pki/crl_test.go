@@ -0,0 +1,144 @@
+package pki
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRevoke(t *testing.T) {
+	directory := newPKI(t)
+	for _, name := range []string{"susi-core", "susi-duktape", "susi-mqtt"} {
+		if err := CreateCertificate(directory, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	crtFile, keyFile := pair(directory, "susi-duktape")
+	revoked := load(t, crtFile)
+	if err := Revoke(directory, "susi-duktape"); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{crtFile, keyFile} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("%v is still there: %v", file, err)
+		}
+	}
+	for _, file := range []string{"certs_by_serial/" + serial(revoked) + ".crt", "private_by_serial/" + serial(revoked) + ".key"} {
+		if _, err := os.Stat(filepath.Join(directory, "pki", "revoked", file)); err != nil {
+			t.Errorf("%v is not kept: %v", file, err)
+		}
+	}
+	states := index(t, directory)
+	if len(states) != 3 || states[serial(revoked)] != "R" {
+		t.Errorf("index.txt has %v, want %v revoked", states, serial(revoked))
+	}
+	if serials := crlSerials(t, directory); !equal(serials, []string{serial(revoked)}) {
+		t.Errorf("crl revokes %v, want %v", serials, serial(revoked))
+	}
+	if err := Revoke(directory, "susi-duktape"); err == nil {
+		t.Error("revoked a certificate which is gone")
+	}
+
+	// the name can be issued again
+	if err := CreateCertificate(directory, "susi-duktape"); err != nil {
+		t.Fatal(err)
+	}
+	verify(t, directory, "susi-duktape")
+	if crt := load(t, crtFile); index(t, directory)[serial(crt)] != "V" {
+		t.Errorf("the new certificate %v is not valid in index.txt", serial(crt))
+	}
+}
+
+func TestMarkRevokedTwice(t *testing.T) {
+	directory := newPKI(t)
+	if err := CreateCertificate(directory, "susi-core"); err != nil {
+		t.Fatal(err)
+	}
+	crtFile, _ := pair(directory, "susi-core")
+	crt := load(t, crtFile)
+	if err := markRevoked(directory, crt, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := markRevoked(directory, crt, "superseded"); err == nil || !strings.Contains(err.Error(), "already revoked") {
+		t.Errorf("revoking twice returned %v", err)
+	}
+}
+
+func TestRevokedEntries(t *testing.T) {
+	index := strings.Join([]string{
+		"V\t360101000000Z\t\t0A\tunknown\t/CN=valid",
+		"R\t360101000000Z\t261018120000Z\t0B\tunknown\t/CN=plain",
+		"R\t360101000000Z\t261018120000Z,superseded\t0C\tunknown\t/CN=renewed",
+		"R\t360101000000Z\t261018120000Z,keyCompromise\tABCDEF\tunknown\t/CN=stolen",
+		"garbage",
+		"",
+	}, "\n")
+	directory := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(directory, "pki"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(directory, "pki", "index.txt"), []byte(index), 0644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := revokedEntries(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	want := []x509.RevocationListEntry{
+		{SerialNumber: big.NewInt(0x0B), RevocationTime: revokedAt},
+		{SerialNumber: big.NewInt(0x0C), RevocationTime: revokedAt, ReasonCode: 4},
+		{SerialNumber: big.NewInt(0xABCDEF), RevocationTime: revokedAt, ReasonCode: 1},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("read %v entries, want %v", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if entry.SerialNumber.Cmp(want[i].SerialNumber) != 0 || !entry.RevocationTime.Equal(want[i].RevocationTime) || entry.ReasonCode != want[i].ReasonCode {
+			t.Errorf("entry %v is %X %v %v, want %X %v %v", i, entry.SerialNumber, entry.RevocationTime, entry.ReasonCode,
+				want[i].SerialNumber, want[i].RevocationTime, want[i].ReasonCode)
+		}
+	}
+
+	for _, line := range []string{
+		"R\t360101000000Z\t261018120000Z\tXYZ\tunknown\t/CN=bad serial",
+		"R\t360101000000Z\tyesterday\t0D\tunknown\t/CN=bad date",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(directory, "pki", "index.txt"), []byte(line+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := revokedEntries(directory); err == nil {
+			t.Errorf("read %q without error", line)
+		}
+	}
+}
+
+func TestCRLNumber(t *testing.T) {
+	directory := newPKI(t)
+	number := func() int64 {
+		block, _ := pem.Decode([]byte(read(t, filepath.Join(directory, "pki", "crl.pem"))))
+		crl, err := x509.ParseRevocationList(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crl.Number.Int64()
+	}
+	first := number()
+	for i := int64(1); i <= 2; i++ {
+		if err := GenerateCRL(directory); err != nil {
+			t.Fatal(err)
+		}
+		if n := number(); n != first+i {
+			t.Errorf("crl number is %v, want %v", n, first+i)
+		}
+	}
+	if content := strings.TrimSpace(read(t, filepath.Join(directory, "pki", "crlnumber"))); content != "04" {
+		t.Errorf("crlnumber holds %q, want the next number as hex like openssl", content)
+	}
+}
@@ -1,56 +1,261 @@
 package pki
 
 import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
 	"fmt"
-	"log"
-	"os/exec"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
 )
 
+const (
+	keyBits  = 2048
+	dhBits   = 2048
+	validFor = 10 * 365 * 24 * time.Hour
+)
+
+// ErrExists is returned when a certificate with the same name was already issued
+var ErrExists = errors.New("certificate already exists")
+
 // Init the pki in a directory
-func Init(directory string) {
-	createScript := fmt.Sprintf(`
-    mkdir -p %v
-    pushd %v
-    wget https://github.com/OpenVPN/easy-rsa/releases/download/3.0.1/EasyRSA-3.0.1.tgz
-    tar xfvz EasyRSA-3.0.1.tgz
-    mv EasyRSA-3.0.1/* .
-    rm -r EasyRSA-3.0.1
-    rm EasyRSA-3.0.1.tgz
-    ./easyrsa init-pki
-    echo "" | ./easyrsa build-ca nopass
-    popd
-  `, directory, directory)
-	cmd := exec.Command("/bin/bash", "-c", createScript)
-	err := cmd.Run()
+func Init(directory string) error {
+	if _, err := os.Stat(filepath.Join(directory, "pki", "ca.crt")); err == nil {
+		return fmt.Errorf("%v: ca %w", directory, ErrExists)
+	}
+	for _, dir := range []string{"issued", "private"} {
+		if err := os.MkdirAll(filepath.Join(directory, "pki", dir), 0700); err != nil {
+			return err
+		}
+	}
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: caName(directory)},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return err
+	}
+	if err := writeKey(filepath.Join(directory, "pki", "private", "ca.key"), key); err != nil {
+		return err
+	}
+	if err := writePEM(filepath.Join(directory, "pki", "ca.crt"), "CERTIFICATE", der, 0644); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(directory, "pki", "index.txt"), nil, 0644); err != nil {
+		return err
+	}
+	return GenerateCRL(directory)
+}
+
+// CreateCertificate creates and signes a client certificate/key pair
+func CreateCertificate(directory, name string) error {
+	return issue(directory, name, x509.ExtKeyUsageClientAuth)
+}
+
+// CreateServerCertificate creates and signes a server certificate/key pair
+func CreateServerCertificate(directory, name string) error {
+	return issue(directory, name, x509.ExtKeyUsageServerAuth)
+}
+
+// CreateDiffiHellman creates diffi hellman parameters
+func CreateDiffiHellman(directory string) error {
+	// p = 2q+1 is a safe prime, p mod 24 == 23 makes 2 a suitable generator
+	q := new(big.Int)
+	p := new(big.Int)
+	for {
+		var err error
+		q, err = rand.Prime(rand.Reader, dhBits-1)
+		if err != nil {
+			return err
+		}
+		p.Lsh(q, 1).Add(p, big.NewInt(1))
+		if new(big.Int).Mod(p, big.NewInt(24)).Int64() == 23 && p.ProbablyPrime(20) {
+			break
+		}
+	}
+	der, err := asn1.Marshal(struct{ P, G *big.Int }{p, big.NewInt(2)})
 	if err != nil {
-		log.Println("Error: ", err)
+		return err
 	}
+	return writePEM(filepath.Join(directory, "pki", "dh.pem"), "DH PARAMETERS", der, 0644)
 }
 
-// CreateCertificate creates and signes a certificate/key pair
-func CreateCertificate(directory, name string) {
-	createScript := fmt.Sprintf(`
-    pushd %v
-    ./easyrsa build-client-full %v nopass
-    popd
-  `, directory, name)
-	cmd := exec.Command("/bin/bash", "-c", createScript)
-	err := cmd.Run()
+func issue(directory, name string, usage x509.ExtKeyUsage) error {
+	crtFile, keyFile := pair(directory, name)
+	if _, err := os.Stat(crtFile); err == nil {
+		return fmt.Errorf("%v: %w", crtFile, ErrExists)
+	}
+	crt, der, key, err := sign(directory, name, usage)
 	if err != nil {
-		log.Println("Error: ", err)
+		return err
+	}
+	if err := writePair(crtFile, keyFile, der, key); err != nil {
+		return err
 	}
+	return appendIndex(directory, crt)
 }
 
-// CreateDiffiHellman creates diffi hellman parameters
-func CreateDiffiHellman(directory string) {
-	createScript := fmt.Sprintf(`
-    pushd %v
-    ./easyrsa gen-dh
-    popd
-  `, directory)
-	cmd := exec.Command("/bin/bash", "-c", createScript)
-	err := cmd.Run()
+// pair returns the certificate and the key file of name
+func pair(directory, name string) (string, string) {
+	return filepath.Join(directory, "pki", "issued", name+".crt"), filepath.Join(directory, "pki", "private", name+".key")
+}
+
+// sign creates a certificate for name with a fresh key, nothing is written yet
+func sign(directory, name string, usage x509.ExtKeyUsage) (*x509.Certificate, []byte, *rsa.PrivateKey, error) {
+	ca, caKey, err := loadCA(directory)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	key, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{usage},
+		BasicConstraintsValid: true,
+	}
+	if usage == x509.ExtKeyUsageServerAuth {
+		template.DNSNames = []string{name}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
 	if err != nil {
-		log.Println("Error: ", err)
+		return nil, nil, nil, err
+	}
+	return template, der, key, nil
+}
+
+// writePair writes a key and its certificate next to their files and moves both in place
+// once they are written, so a failed write leaves the previous pair untouched
+func writePair(crtFile, keyFile string, der []byte, key *rsa.PrivateKey) error {
+	if err := writeKey(keyFile+".new", key); err != nil {
+		return err
+	}
+	if err := writePEM(crtFile+".new", "CERTIFICATE", der, 0644); err != nil {
+		os.Remove(keyFile + ".new")
+		return err
+	}
+	if err := os.Rename(keyFile+".new", keyFile); err != nil {
+		return err
+	}
+	return os.Rename(crtFile+".new", crtFile)
+}
+
+// appendIndex records an issued certificate in the openssl style index.txt easy-rsa uses
+func appendIndex(directory string, crt *x509.Certificate) error {
+	file, err := os.OpenFile(filepath.Join(directory, "pki", "index.txt"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(file, "V\t%v\t\t%X\tunknown\t/CN=%v\n", crt.NotAfter.UTC().Format("060102150405Z"), crt.SerialNumber, crt.Subject.CommonName); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func loadCA(directory string) (*x509.Certificate, crypto.Signer, error) {
+	ca, err := LoadCertificate(filepath.Join(directory, "pki", "ca.crt"))
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := loadKey(filepath.Join(directory, "pki", "private", "ca.key"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return ca, key, nil
+}
+
+// LoadCertificate reads a PEM encoded certificate
+func LoadCertificate(file string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, fmt.Errorf("%v: no PEM certificate found", file)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// loadKey reads a PEM encoded private key as written by us or by easy-rsa
+func loadKey(file string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%v: no PEM key found", file)
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+	}
+	return nil, fmt.Errorf("%v: unsupported key type %v", file, block.Type)
+}
+
+func writeKey(file string, key *rsa.PrivateKey) error {
+	return writePEM(file, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key), 0600)
+}
+
+func writePEM(file, blockType string, der []byte, mode os.FileMode) error {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	return ioutil.WriteFile(file, data, mode)
+}
+
+func newSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+// caName derives the CA common name from the pki directory, "gateway/pki" becomes "gateway CA"
+func caName(directory string) string {
+	name := filepath.Base(filepath.Clean(directory))
+	if name == "pki" {
+		name = filepath.Base(filepath.Dir(filepath.Clean(directory)))
 	}
+	return name + " CA"
 }
@@ -0,0 +1,256 @@
+package pki
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newPKI initializes the pki of the node gw in a temporary directory
+func newPKI(t *testing.T) string {
+	t.Helper()
+	directory := filepath.Join(t.TempDir(), "gw", "pki")
+	if err := Init(directory); err != nil {
+		t.Fatal(err)
+	}
+	return directory
+}
+
+func load(t *testing.T, file string) *x509.Certificate {
+	t.Helper()
+	crt, err := LoadCertificate(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt
+}
+
+func serial(crt *x509.Certificate) string {
+	return fmt.Sprintf("%X", crt.SerialNumber)
+}
+
+// verify checks that the certificate of name is signed by the ca and matches its key
+func verify(t *testing.T, directory, name string) {
+	t.Helper()
+	crtFile, keyFile := pair(directory, name)
+	crt := load(t, crtFile)
+	roots := x509.NewCertPool()
+	roots.AddCert(load(t, filepath.Join(directory, "pki", "ca.crt")))
+	if _, err := crt.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		t.Errorf("%v: %v", name, err)
+	}
+	key, err := loadKey(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !public.Equal(crt.PublicKey) {
+		t.Errorf("%v does not match its key", name)
+	}
+}
+
+// index returns the state of every serial in index.txt
+func index(t *testing.T, directory string) map[string]string {
+	t.Helper()
+	states := map[string]string{}
+	for _, line := range strings.Split(read(t, filepath.Join(directory, "pki", "index.txt")), "\n") {
+		if fields := strings.Split(line, "\t"); len(fields) == 6 {
+			states[fields[3]] = fields[0]
+		}
+	}
+	return states
+}
+
+// crlSerials returns the sorted serials of the crl after checking its signature
+func crlSerials(t *testing.T, directory string) []string {
+	t.Helper()
+	block, _ := pem.Decode([]byte(read(t, filepath.Join(directory, "pki", "crl.pem"))))
+	if block == nil || block.Type != "X509 CRL" {
+		t.Fatal("crl.pem holds no crl")
+	}
+	crl, err := x509.ParseRevocationList(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crl.CheckSignatureFrom(load(t, filepath.Join(directory, "pki", "ca.crt"))); err != nil {
+		t.Errorf("crl: %v", err)
+	}
+	var serials []string
+	for _, entry := range crl.RevokedCertificateEntries {
+		serials = append(serials, fmt.Sprintf("%X", entry.SerialNumber))
+	}
+	sort.Strings(serials)
+	return serials
+}
+
+func equal(a, b []string) bool {
+	return strings.Join(a, " ") == strings.Join(b, " ")
+}
+
+func TestInit(t *testing.T) {
+	directory := newPKI(t)
+	ca := load(t, filepath.Join(directory, "pki", "ca.crt"))
+	if !ca.IsCA || ca.Subject.CommonName != "gw CA" || ca.KeyUsage != x509.KeyUsageCertSign|x509.KeyUsageCRLSign {
+		t.Errorf("ca is %v with ca %v and usage %v", ca.Subject.CommonName, ca.IsCA, ca.KeyUsage)
+	}
+	if err := ca.CheckSignatureFrom(ca); err != nil {
+		t.Errorf("ca is not self-signed: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(directory, "pki", "private", "ca.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("ca.key has mode %v", info.Mode().Perm())
+	}
+	if len(index(t, directory)) != 0 {
+		t.Errorf("a new pki has certificates in index.txt")
+	}
+	if serials := crlSerials(t, directory); len(serials) != 0 {
+		t.Errorf("the crl of a new pki revokes %v", serials)
+	}
+	if err := Init(directory); !errors.Is(err, ErrExists) {
+		t.Errorf("initializing again returned %v, want ErrExists", err)
+	}
+}
+
+func TestIssue(t *testing.T) {
+	directory := newPKI(t)
+	for _, test := range []struct {
+		name   string
+		create func(directory, name string) error
+		usage  x509.ExtKeyUsage
+		dns    []string
+	}{
+		{"susi-core", CreateServerCertificate, x509.ExtKeyUsageServerAuth, []string{"susi-core"}},
+		{"susi-duktape", CreateCertificate, x509.ExtKeyUsageClientAuth, nil},
+		{"gw2", CreateCertificate, x509.ExtKeyUsageClientAuth, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.create(directory, test.name); err != nil {
+				t.Fatal(err)
+			}
+			crtFile, keyFile := pair(directory, test.name)
+			crt := load(t, crtFile)
+			if crt.Subject.CommonName != test.name || crt.IsCA {
+				t.Errorf("issued %v with ca %v", crt.Subject.CommonName, crt.IsCA)
+			}
+			if len(crt.ExtKeyUsage) != 1 || crt.ExtKeyUsage[0] != test.usage {
+				t.Errorf("usage is %v, want %v", crt.ExtKeyUsage, test.usage)
+			}
+			if !equal(crt.DNSNames, test.dns) {
+				t.Errorf("dns names are %v, want %v", crt.DNSNames, test.dns)
+			}
+			verify(t, directory, test.name)
+			if state := index(t, directory)[serial(crt)]; state != "V" {
+				t.Errorf("index.txt has %q for %X, want V", state, crt.SerialNumber)
+			}
+			if _, err := os.Stat(keyFile + ".new"); !os.IsNotExist(err) {
+				t.Errorf("the temporary key is left behind: %v", err)
+			}
+			if err := test.create(directory, test.name); !errors.Is(err, ErrExists) {
+				t.Errorf("issuing again returned %v, want ErrExists", err)
+			}
+		})
+	}
+	if states := index(t, directory); len(states) != 3 {
+		t.Errorf("index.txt has %v, want three valid certificates", states)
+	}
+}
+
+func TestIssueWithoutCA(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "pki")
+	if err := CreateCertificate(directory, "susi-core"); err == nil {
+		t.Fatal("issued a certificate without ca")
+	}
+	crtFile, keyFile := pair(directory, "susi-core")
+	for _, file := range []string{crtFile, keyFile} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("%v is written: %v", file, err)
+		}
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalECPrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, test := range []struct {
+		name, blockType string
+		der             []byte
+		public          crypto.PublicKey
+	}{
+		{"rsa", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), rsaKey.Public()},
+		{"ec", "EC PRIVATE KEY", ecDER, ecKey.Public()},
+		{"pkcs8", "PRIVATE KEY", pkcs8, edKey.Public()},
+		{"unsupported", "DSA PRIVATE KEY", []byte{0}, nil},
+	} {
+		file := filepath.Join(dir, test.name+".key")
+		if err := writePEM(file, test.blockType, test.der, 0600); err != nil {
+			t.Fatal(err)
+		}
+		key, err := loadKey(file)
+		if test.public == nil {
+			if err == nil {
+				t.Errorf("%v: loaded %T", test.name, key)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if !key.Public().(interface{ Equal(crypto.PublicKey) bool }).Equal(test.public) {
+			t.Errorf("%v: loaded another key", test.name)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "empty.key"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadKey(filepath.Join(dir, "empty.key")); err == nil {
+		t.Error("loaded a key from an empty file")
+	}
+}
+
+func TestCAName(t *testing.T) {
+	for _, test := range []struct {
+		directory, want string
+	}{
+		{"gateway/pki", "gateway CA"},
+		{"gateway/pki/", "gateway CA"},
+		{"gateway", "gateway CA"},
+		{"/home/susi/project/gw2/pki", "gw2 CA"},
+	} {
+		if name := caName(test.directory); name != test.want {
+			t.Errorf("caName(%q) = %q, want %q", test.directory, name, test.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package pki
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Info describes a certificate on disk
+type Info struct {
+	File        string
+	Subject     string
+	Issuer      string
+	NotAfter    time.Time
+	KeyType     string
+	Fingerprint string
+	IsCA        bool
+}
+
+// Inspect reads the certificate in file
+func Inspect(file string) (*Info, error) {
+	crt, err := LoadCertificate(file)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(crt.Raw)
+	return &Info{
+		File:        file,
+		Subject:     crt.Subject.CommonName,
+		Issuer:      crt.Issuer.CommonName,
+		NotAfter:    crt.NotAfter,
+		KeyType:     keyType(crt),
+		Fingerprint: fmt.Sprintf("%X", sum),
+		IsCA:        crt.IsCA,
+	}, nil
+}
+
+// List returns the ca and all issued certificates of a pki
+func List(directory string) ([]*Info, error) {
+	files, err := filepath.Glob(filepath.Join(directory, "pki", "issued", "*.crt"))
+	if err != nil {
+		return nil, err
+	}
+	files = append([]string{filepath.Join(directory, "pki", "ca.crt")}, files...)
+	infos := make([]*Info, 0, len(files))
+	for _, file := range files {
+		info, err := Inspect(file)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Renew reissues the certificate of name with a fresh key. The old certificate is revoked
+// as superseded only after the new one is in place, a failed renewal leaves it valid.
+func Renew(directory, name string) error {
+	crtFile, keyFile := pair(directory, name)
+	old, err := LoadCertificate(crtFile)
+	if err != nil {
+		return err
+	}
+	usage := x509.ExtKeyUsageClientAuth
+	if len(old.ExtKeyUsage) > 0 {
+		usage = old.ExtKeyUsage[0]
+	}
+	crt, der, key, err := sign(directory, name, usage)
+	if err != nil {
+		return err
+	}
+	if err := archive(directory, name, old); err != nil {
+		return err
+	}
+	if err := writePair(crtFile, keyFile, der, key); err != nil {
+		return err
+	}
+	if err := appendIndex(directory, crt); err != nil {
+		return err
+	}
+	if err := markRevoked(directory, old, "superseded"); err != nil {
+		return err
+	}
+	return GenerateCRL(directory)
+}
+
+// RenewCA re-signs the ca with its own key, certificates issued by the old ca stay valid
+func RenewCA(directory string) error {
+	ca, caKey, err := loadCA(directory)
+	if err != nil {
+		return err
+	}
+	serial, err := newSerial()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               ca.Subject,
+		SubjectKeyId:          ca.SubjectKeyId,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, caKey.Public(), caKey)
+	if err != nil {
+		return err
+	}
+	if err := writePEM(filepath.Join(directory, "pki", "ca.crt"), "CERTIFICATE", der, 0644); err != nil {
+		return err
+	}
+	return GenerateCRL(directory)
+}
+
+func keyType(crt *x509.Certificate) string {
+	switch key := crt.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %v", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + key.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return strings.TrimPrefix(crt.PublicKeyAlgorithm.String(), "x509.")
+}
@@ -0,0 +1,124 @@
+package pki
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenew(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		create func(directory, name string) error
+		usage  x509.ExtKeyUsage
+	}{
+		{"susi-core", CreateServerCertificate, x509.ExtKeyUsageServerAuth},
+		{"susi-duktape", CreateCertificate, x509.ExtKeyUsageClientAuth},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			directory := newPKI(t)
+			if err := test.create(directory, test.name); err != nil {
+				t.Fatal(err)
+			}
+			crtFile, _ := pair(directory, test.name)
+			old := load(t, crtFile)
+			if err := Renew(directory, test.name); err != nil {
+				t.Fatal(err)
+			}
+			crt := load(t, crtFile)
+			if crt.SerialNumber.Cmp(old.SerialNumber) == 0 {
+				t.Fatalf("the renewed certificate has the old serial %X", old.SerialNumber)
+			}
+			if len(crt.ExtKeyUsage) != 1 || crt.ExtKeyUsage[0] != test.usage {
+				t.Errorf("renewed usage is %v, want %v", crt.ExtKeyUsage, test.usage)
+			}
+			verify(t, directory, test.name)
+			want := map[string]string{serial(old): "R", serial(crt): "V"}
+			if states := index(t, directory); fmt.Sprint(states) != fmt.Sprint(want) {
+				t.Errorf("index.txt has %v, want %v", states, want)
+			}
+			if !strings.Contains(read(t, filepath.Join(directory, "pki", "index.txt")), ",superseded\t"+serial(old)) {
+				t.Errorf("the old certificate is not revoked as superseded")
+			}
+			if revoked := crlSerials(t, directory); !equal(revoked, []string{serial(old)}) {
+				t.Errorf("crl holds %v, want the old serial %v", revoked, serial(old))
+			}
+			if _, err := os.Stat(filepath.Join(directory, "pki", "revoked", "certs_by_serial", serial(old)+".crt")); err != nil {
+				t.Errorf("the old certificate is not kept: %v", err)
+			}
+		})
+	}
+}
+
+func TestRenewFailureKeepsTheOldCertificate(t *testing.T) {
+	directory := newPKI(t)
+	if err := CreateCertificate(directory, "susi-duktape"); err != nil {
+		t.Fatal(err)
+	}
+	crtFile, keyFile := pair(directory, "susi-duktape")
+	old := read(t, crtFile)
+	oldKey := read(t, keyFile)
+	crl := read(t, filepath.Join(directory, "pki", "crl.pem"))
+	// without the ca key nothing can be issued
+	caKey := filepath.Join(directory, "pki", "private", "ca.key")
+	if err := os.Rename(caKey, caKey+".away"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Renew(directory, "susi-duktape"); err == nil {
+		t.Fatal("renewed without the ca key")
+	}
+	if err := os.Rename(caKey+".away", caKey); err != nil {
+		t.Fatal(err)
+	}
+	if read(t, crtFile) != old || read(t, keyFile) != oldKey {
+		t.Error("the failed renewal replaced the certificate or its key")
+	}
+	if read(t, filepath.Join(directory, "pki", "crl.pem")) != crl {
+		t.Error("the failed renewal changed the crl")
+	}
+	for s, state := range index(t, directory) {
+		if state != "V" {
+			t.Errorf("the failed renewal marked %v as %v", s, state)
+		}
+	}
+	verify(t, directory, "susi-duktape")
+}
+
+func TestList(t *testing.T) {
+	directory := newPKI(t)
+	for _, name := range []string{"susi-core", "susi-mqtt"} {
+		if err := CreateCertificate(directory, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	infos, err := List(directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var subjects []string
+	for _, info := range infos {
+		subjects = append(subjects, info.Subject)
+		if info.KeyType != "RSA 2048" || len(info.Fingerprint) != 64 {
+			t.Errorf("%v: key %v, fingerprint %v", info.Subject, info.KeyType, info.Fingerprint)
+		}
+		if info.IsCA != (info.Subject == "gw CA") || info.Issuer != "gw CA" {
+			t.Errorf("%v: ca %v, issuer %v", info.Subject, info.IsCA, info.Issuer)
+		}
+	}
+	if !equal(subjects, []string{"gw CA", "susi-core", "susi-mqtt"}) {
+		t.Errorf("listed %v", subjects)
+	}
+}
+
+func read(t *testing.T, file string) string {
+	t.Helper()
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,149 @@
+package project
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// DefaultSubnet is the subnet of the nodes unless the project selects another, it is the one of the default rkt network
+const DefaultSubnet = "172.16.28.0/24"
+
+// SubnetOrDefault returns the subnet of the nodes
+func (project *Project) SubnetOrDefault() string {
+	if project.Subnet == "" {
+		return DefaultSubnet
+	}
+	return project.Subnet
+}
+
+// ParseSubnet parses an IPv4 subnet with room for the gateway and at least one node
+func ParseSubnet(subnet string) (*net.IPNet, error) {
+	ip, network, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet %v: %v", subnet, err)
+	}
+	ones, bits := network.Mask.Size()
+	if ip.To4() == nil || bits != 32 {
+		return nil, fmt.Errorf("invalid subnet %v: only IPv4 subnets are supported", subnet)
+	}
+	if ones > 30 {
+		return nil, fmt.Errorf("invalid subnet %v: it has no room for nodes, use /30 or larger", subnet)
+	}
+	if !ip.Equal(network.IP) {
+		return nil, fmt.Errorf("invalid subnet %v: did you mean %v?", subnet, network)
+	}
+	return network, nil
+}
+
+// hosts returns the first and the last address of a subnet nodes may use,
+// the network address, the gateway (the first host) and the broadcast address are left out
+func hosts(network *net.IPNet) (uint32, uint32) {
+	base := binary.BigEndian.Uint32(network.IP.To4())
+	ones, _ := network.Mask.Size()
+	size := uint32(1) << uint(32-ones)
+	return base + 2, base + size - 2
+}
+
+func toIP(n uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+// Gateway returns the first address of the subnet, the host side of the pod network
+func (project *Project) Gateway() (string, error) {
+	network, err := ParseSubnet(project.SubnetOrDefault())
+	if err != nil {
+		return "", err
+	}
+	first, _ := hosts(network)
+	return toIP(first - 1).String(), nil
+}
+
+// owner returns the id of the node using an address, except the node skip
+func (project *Project) owner(ip, skip string) string {
+	for _, id := range project.IDs() {
+		if id != skip && project.Nodes[id].IP == ip {
+			return id
+		}
+	}
+	return ""
+}
+
+// AllocateIP returns the lowest address of the subnet no node uses,
+// addresses of deleted nodes are free again.
+func (project *Project) AllocateIP() (string, error) {
+	network, err := ParseSubnet(project.SubnetOrDefault())
+	if err != nil {
+		return "", err
+	}
+	first, last := hosts(network)
+	for n := first; n <= last; n++ {
+		if ip := toIP(n).String(); project.owner(ip, "") == "" {
+			return ip, nil
+		}
+	}
+	return "", fmt.Errorf("subnet %v is full, select a larger one", network)
+}
+
+// CheckIP fails if the node id cannot use the address, because it is outside of the subnet,
+// reserved or used by another node
+func (project *Project) CheckIP(id, ip string) error {
+	network, err := ParseSubnet(project.SubnetOrDefault())
+	if err != nil {
+		return err
+	}
+	parsed := net.ParseIP(ip).To4()
+	if parsed == nil {
+		return fmt.Errorf("invalid IPv4 address: %v", ip)
+	}
+	if !network.Contains(parsed) {
+		return fmt.Errorf("%v is outside of the subnet %v", ip, network)
+	}
+	n := binary.BigEndian.Uint32(parsed)
+	if first, last := hosts(network); n < first || n > last {
+		return fmt.Errorf("%v is reserved for the network, the gateway or broadcasts of %v", ip, network)
+	}
+	if other := project.owner(parsed.String(), id); other != "" {
+		return fmt.Errorf("%v is already used by %v", ip, other)
+	}
+	return nil
+}
+
+// CheckIPs fails on the first node whose address conflicts with another node or the subnet
+func (project *Project) CheckIPs() error {
+	for _, id := range project.IDs() {
+		if err := project.CheckIP(id, project.Nodes[id].IP); err != nil {
+			return fmt.Errorf("node %v: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// SetSubnet selects the subnet of the nodes, nodes outside of it get new addresses.
+// It returns the ids of the moved nodes.
+func (project *Project) SetSubnet(subnet string) ([]string, error) {
+	network, err := ParseSubnet(subnet)
+	if err != nil {
+		return nil, err
+	}
+	project.Subnet = network.String()
+	if project.Subnet == DefaultSubnet {
+		project.Subnet = ""
+	}
+	var moved []string
+	for _, id := range project.IDs() {
+		node := project.Nodes[id]
+		if project.CheckIP(id, node.IP) == nil {
+			continue
+		}
+		// release the address first, it may be taken by another node
+		node.IP = ""
+		if node.IP, err = project.AllocateIP(); err != nil {
+			return nil, err
+		}
+		moved = append(moved, id)
+	}
+	return moved, nil
+}
@@ -0,0 +1,164 @@
+package project
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// withNodes returns a project in subnet with a node per address, the ids are n1, n2, ...
+func withNodes(subnet string, ips ...string) *Project {
+	project := &Project{Version: Version, Subnet: subnet, Nodes: map[string]*Node{}}
+	for i, ip := range ips {
+		id := fmt.Sprintf("n%v", i+1)
+		project.Nodes[id] = &Node{ID: id, IP: ip}
+	}
+	return project
+}
+
+func TestParseSubnet(t *testing.T) {
+	for _, test := range []struct {
+		subnet, want, err string
+	}{
+		{"172.16.28.0/24", "172.16.28.0/24", ""},
+		{"10.0.0.0/8", "10.0.0.0/8", ""},
+		{"192.168.1.4/30", "192.168.1.4/30", ""},
+		{"192.168.1.0/31", "", "no room for nodes"},
+		{"10.1.2.3/24", "", "did you mean 10.1.2.0/24"},
+		{"fd00::/64", "", "only IPv4"},
+		{"10.0.0.0", "", "invalid subnet"},
+	} {
+		network, err := ParseSubnet(test.subnet)
+		switch {
+		case test.err != "":
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("ParseSubnet(%q) = %v, %v, want an error with %q", test.subnet, network, err, test.err)
+			}
+		case err != nil || network.String() != test.want:
+			t.Errorf("ParseSubnet(%q) = %v, %v, want %v", test.subnet, network, err, test.want)
+		}
+	}
+}
+
+func TestGateway(t *testing.T) {
+	for _, test := range []struct {
+		subnet, want string
+	}{
+		{"", "172.16.28.1"},
+		{"10.1.0.0/16", "10.1.0.1"},
+		{"192.168.1.4/30", "192.168.1.5"},
+	} {
+		if gateway, err := withNodes(test.subnet).Gateway(); err != nil || gateway != test.want {
+			t.Errorf("gateway of %q is %v, %v, want %v", test.subnet, gateway, err, test.want)
+		}
+	}
+}
+
+func TestAllocateIP(t *testing.T) {
+	for _, test := range []struct {
+		name, subnet string
+		used         []string
+		want         string
+	}{
+		{"first", "", nil, "172.16.28.2"},
+		{"next", "", []string{"172.16.28.2", "172.16.28.3"}, "172.16.28.4"},
+		{"gap", "", []string{"172.16.28.2", "172.16.28.4"}, "172.16.28.3"},
+		{"last", "10.0.0.0/29", []string{"10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5"}, "10.0.0.6"},
+		{"full", "10.0.0.0/29", []string{"10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"}, ""},
+		{"single", "192.168.1.4/30", nil, "192.168.1.6"},
+		{"single full", "192.168.1.4/30", []string{"192.168.1.6"}, ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ip, err := withNodes(test.subnet, test.used...).AllocateIP()
+			if test.want == "" {
+				if err == nil || !strings.Contains(err.Error(), "is full") {
+					t.Errorf("allocated %v, %v in a full subnet", ip, err)
+				}
+				return
+			}
+			if err != nil || ip != test.want {
+				t.Errorf("allocated %v, %v, want %v", ip, err, test.want)
+			}
+		})
+	}
+}
+
+func TestAllocateIPReusesDeletedNodes(t *testing.T) {
+	project := withNodes("10.0.0.0/29")
+	for i := 1; i <= 5; i++ {
+		ip, err := project.AllocateIP()
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := fmt.Sprintf("n%v", i)
+		project.Nodes[id] = &Node{ID: id, IP: ip}
+	}
+	if ip, err := project.AllocateIP(); err == nil {
+		t.Fatalf("allocated %v in a full subnet", ip)
+	}
+	delete(project.Nodes, "n3")
+	if ip, err := project.AllocateIP(); err != nil || ip != "10.0.0.4" {
+		t.Errorf("allocated %v, %v after deleting n3, want its address 10.0.0.4", ip, err)
+	}
+}
+
+func TestCheckIP(t *testing.T) {
+	project := withNodes("", "172.16.28.2", "172.16.28.3")
+	for _, test := range []struct {
+		id, ip, err string
+	}{
+		{"new", "172.16.28.10", ""},
+		{"n1", "172.16.28.2", ""},
+		{"new", "172.16.28.2", "already used by n1"},
+		{"n1", "172.16.28.3", "already used by n2"},
+		{"new", "172.16.29.2", "outside of the subnet"},
+		{"new", "172.16.28.0", "reserved"},
+		{"new", "172.16.28.1", "reserved"},
+		{"new", "172.16.28.255", "reserved"},
+		{"new", "172.16.28.254", ""},
+		{"new", "fd00::1", "invalid IPv4 address"},
+		{"new", "gw", "invalid IPv4 address"},
+	} {
+		err := project.CheckIP(test.id, test.ip)
+		if test.err == "" && err != nil || test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("CheckIP(%v, %v) = %v, want %q", test.id, test.ip, err, test.err)
+		}
+	}
+	if err := withNodes("", "172.16.28.2", "172.16.28.2").CheckIPs(); err == nil || !strings.Contains(err.Error(), "node n1") {
+		t.Errorf("CheckIPs with a duplicate address returned %v", err)
+	}
+}
+
+func TestSetSubnet(t *testing.T) {
+	project := withNodes("", "172.16.28.2", "10.1.0.2", "172.16.28.3")
+	moved, err := project.SetSubnet("10.1.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(moved, []string{"n1", "n3"}) {
+		t.Errorf("moved %v, want n1 n3", moved)
+	}
+	if project.Subnet != "10.1.0.0/24" {
+		t.Errorf("subnet is %q", project.Subnet)
+	}
+	if err := project.CheckIPs(); err != nil {
+		t.Error(err)
+	}
+	if ip := project.Nodes["n2"].IP; ip != "10.1.0.2" {
+		t.Errorf("n2 moved to %v, it was in the subnet", ip)
+	}
+
+	if _, err := project.SetSubnet(DefaultSubnet); err != nil {
+		t.Fatal(err)
+	}
+	if project.Subnet != "" {
+		t.Errorf("the default subnet is stored as %q", project.Subnet)
+	}
+	if _, err := withNodes("", "172.16.28.2", "172.16.28.3").SetSubnet("192.168.1.4/30"); err == nil {
+		t.Error("moved two nodes into a subnet for one")
+	}
+}
+
+func equal(a, b []string) bool {
+	return strings.Join(a, " ") == strings.Join(b, " ")
+}
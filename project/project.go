@@ -0,0 +1,248 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// File is the name of the project manifest
+	File = "susi-project.json"
+	// Version is the manifest version written by this susi-dev
+	Version = 1
+
+	legacyFile = "nodes.txt"
+)
+
+// State is the runtime state of a node
+type State struct {
+	Runtime   string `json:"runtime,omitempty"`
+	PodID     string `json:"podID,omitempty"`
+	SystemdID string `json:"systemdID,omitempty"`
+}
+
+// Node is a node of multiple services
+type Node struct {
+	ID   string `json:"id"`
+	IP   string `json:"ip"`
+	Fqdn string `json:"fqdn"`
+	// Arch is the architecture the node is built for, empty is amd64
+	Arch       string   `json:"arch,omitempty"`
+	Components []string `json:"components"`
+	// Removed components are disabled and deleted on the targets when deploying
+	Removed     []string `json:"removed,omitempty"`
+	Connections []string `json:"connections"`
+	Targets     []string `json:"targets"`
+	State       State    `json:"state"`
+}
+
+// Project is the manifest of a susi-dev project
+type Project struct {
+	Version int `json:"version"`
+	// Runtime is the container runtime used to build and run the nodes
+	Runtime string `json:"runtime,omitempty"`
+	// Subnet holds the addresses of the nodes, empty is DefaultSubnet
+	Subnet string `json:"subnet,omitempty"`
+	// DNS lets the pods resolve the nodes with susi-dev dns serve instead of /etc/hosts
+	DNS   bool             `json:"dns,omitempty"`
+	Nodes map[string]*Node `json:"nodes"`
+}
+
+// Load loads the project manifest from the working directory.
+// If there is no manifest but a legacy nodes.txt, it is migrated.
+func Load() (*Project, error) {
+	data, err := ioutil.ReadFile(File)
+	if os.IsNotExist(err) {
+		if _, err := os.Stat(legacyFile); err == nil {
+			return migrate(legacyFile)
+		}
+		return &Project{Version: Version, Nodes: map[string]*Node{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	project := &Project{}
+	if err := json.Unmarshal(data, project); err != nil {
+		return nil, fmt.Errorf("%v: %v", File, err)
+	}
+	if project.Version > Version {
+		return nil, fmt.Errorf("%v has version %v, this susi-dev supports up to %v", File, project.Version, Version)
+	}
+	project.Version = Version
+	if project.Nodes == nil {
+		project.Nodes = map[string]*Node{}
+	}
+	for id, node := range project.Nodes {
+		node.ID = id
+	}
+	return project, nil
+}
+
+// Save writes the project manifest to the working directory
+func (project *Project) Save() error {
+	data, err := json.MarshalIndent(project, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(File, append(data, '\n'), 0644)
+}
+
+// Node returns the node with the given id
+func (project *Project) Node(id string) (*Node, error) {
+	node, ok := project.Nodes[id]
+	if !ok {
+		return nil, fmt.Errorf("no such node: %v", id)
+	}
+	return node, nil
+}
+
+// Set adds or replaces a node
+func (project *Project) Set(node *Node) {
+	project.Nodes[node.ID] = node
+}
+
+// Delete removes a node, its address is free for the next node
+func (project *Project) Delete(id string) {
+	delete(project.Nodes, id)
+}
+
+// IDs returns the sorted ids of all nodes
+func (project *Project) IDs() []string {
+	ids := make([]string, 0, len(project.Nodes))
+	for id := range project.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Names returns the IPs of the nodes by node id and FQDN, names are lower case
+func (project *Project) Names() map[string]string {
+	names := map[string]string{}
+	for _, id := range project.IDs() {
+		node := project.Nodes[id]
+		names[strings.ToLower(node.ID)] = node.IP
+		if node.Fqdn != "" {
+			names[strings.ToLower(node.Fqdn)] = node.IP
+		}
+	}
+	return names
+}
+
+// WriteHosts writes a hosts file for the containers, it lists the IP, the id and the FQDN of every node.
+// With DNS the nodes are left out, they would shadow the current addresses served by the responder.
+func (project *Project) WriteHosts(file string) error {
+	data := "127.0.0.1 localhost\n::1 localhost\n"
+	if project.DNS {
+		data += "# the nodes are resolved by susi-dev dns serve\n"
+	} else {
+		for _, id := range project.IDs() {
+			node := project.Nodes[id]
+			data += node.IP + " " + node.ID
+			if node.Fqdn != "" && node.Fqdn != node.ID {
+				data += " " + node.Fqdn
+			}
+			data += "\n"
+		}
+	}
+	return ioutil.WriteFile(file, []byte(data), 0644)
+}
+
+// AddComponent records a component on the node
+func (node *Node) AddComponent(component string) {
+	node.Components = addString(node.Components, component)
+	node.Removed = removeString(node.Removed, component)
+}
+
+// RemoveComponent removes a component from the node and remembers it for the next deploy
+func (node *Node) RemoveComponent(component string) {
+	node.Components = removeString(node.Components, component)
+	node.Removed = addString(node.Removed, component)
+}
+
+// AddConnection records a connection to another node
+func (node *Node) AddConnection(peer string) {
+	node.Connections = addString(node.Connections, peer)
+}
+
+// RemoveConnection forgets the connection to another node
+func (node *Node) RemoveConnection(peer string) {
+	node.Connections = removeString(node.Connections, peer)
+}
+
+// AddTarget records a host the node has been deployed to
+func (node *Node) AddTarget(target string) {
+	node.Targets = addString(node.Targets, target)
+}
+
+func addString(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	list = append(list, value)
+	sort.Strings(list)
+	return list
+}
+
+// removeString returns a new list without value, callers may still range over the old one
+func removeString(list []string, value string) []string {
+	var result []string
+	for _, v := range list {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// migrate converts a legacy nodes.txt into a project manifest
+func migrate(file string) (*Project, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	project := &Project{Version: Version, Nodes: map[string]*Node{}}
+	for _, line := range strings.Split(string(data), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		// nodes.txt was written as "ip id fqdn podID systemdID" where the
+		// last two columns may be empty, so fields must not be collapsed.
+		parts := strings.Split(line, " ")
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("%v: malformed line %q", file, line)
+		}
+		node := &Node{IP: parts[0], ID: parts[1], Fqdn: parts[2]}
+		if len(parts) > 3 {
+			node.State.PodID = parts[3]
+		}
+		if len(parts) > 4 {
+			node.State.SystemdID = parts[4]
+		}
+		units, _ := filepath.Glob(node.ID + "/configs/*.service")
+		for _, unit := range units {
+			node.AddComponent(strings.TrimSuffix(filepath.Base(unit), ".service"))
+		}
+		keys, _ := filepath.Glob(node.ID + "/foreignKeys/" + node.ID + "@*.crt")
+		for _, key := range keys {
+			peer := strings.TrimSuffix(filepath.Base(key), ".crt")
+			node.AddConnection(strings.TrimPrefix(peer, node.ID+"@"))
+		}
+		project.Set(node)
+	}
+	if err := project.Save(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(file, file+".bak"); err != nil {
+		return nil, err
+	}
+	fmt.Printf("migrated %v to %v\n", file, File)
+	return project, nil
+}
@@ -0,0 +1,84 @@
+package project
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRemoveConnectionWhileRanging(t *testing.T) {
+	for _, peers := range [][]string{
+		{"a"},
+		{"a", "b"},
+		{"a", "b", "c"},
+		{"a", "b", "c", "d", "e"},
+	} {
+		t.Run(strings.Join(peers, ","), func(t *testing.T) {
+			node := &Node{ID: "gw"}
+			for _, peer := range peers {
+				node.AddConnection(peer)
+			}
+			var visited []string
+			for _, peer := range node.Connections {
+				visited = append(visited, peer)
+				node.RemoveConnection(peer)
+			}
+			if strings.Join(visited, ",") != strings.Join(peers, ",") {
+				t.Errorf("visited %v while removing, want every peer once: %v", visited, peers)
+			}
+			if len(node.Connections) != 0 {
+				t.Errorf("connections left: %v", node.Connections)
+			}
+		})
+	}
+}
+
+func TestRemoveComponent(t *testing.T) {
+	node := &Node{ID: "gw"}
+	for _, component := range []string{"susi-core", "susi-mqtt", "susi-duktape"} {
+		node.AddComponent(component)
+	}
+	components := node.Components
+	node.RemoveComponent("susi-duktape")
+	if strings.Join(components, ",") != "susi-core,susi-duktape,susi-mqtt" {
+		t.Errorf("removing changed the previous list to %v", components)
+	}
+	if strings.Join(node.Components, ",") != "susi-core,susi-mqtt" || strings.Join(node.Removed, ",") != "susi-duktape" {
+		t.Errorf("components %v, removed %v", node.Components, node.Removed)
+	}
+	node.AddComponent("susi-duktape")
+	if len(node.Removed) != 0 || len(node.Components) != 3 {
+		t.Errorf("adding again left components %v, removed %v", node.Components, node.Removed)
+	}
+}
+
+func TestWriteHosts(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		dns  bool
+		want string
+	}{
+		{"hosts", false, "127.0.0.1 localhost\n::1 localhost\n172.16.28.2 gw gw.example.com\n172.16.28.4 pump\n172.16.28.3 sensor\n"},
+		{"dns", true, "127.0.0.1 localhost\n::1 localhost\n# the nodes are resolved by susi-dev dns serve\n"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			project := &Project{DNS: test.dns, Nodes: map[string]*Node{
+				"sensor": {ID: "sensor", IP: "172.16.28.3"},
+				"gw":     {ID: "gw", IP: "172.16.28.2", Fqdn: "gw.example.com"},
+				"pump":   {ID: "pump", IP: "172.16.28.4", Fqdn: "pump"},
+			}}
+			file := filepath.Join(t.TempDir(), ".hosts")
+			if err := project.WriteHosts(file); err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != test.want {
+				t.Errorf("hosts file is\n%s\nwant\n%v", data, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/webvariants/susi-dev/arch"
+	"github.com/webvariants/susi-dev/container"
+	"github.com/webvariants/susi-dev/project"
+)
+
+// outputFormats are the values of --output
+var outputFormats = []string{"text", "json"}
+
+// output is the format selected with --output
+var output *string
+
+// outputFlag registers --output
+func outputFlag(f *flag.FlagSet) {
+	output = f.String("output", "text", "output format ("+strings.Join(outputFormats, ", ")+")")
+}
+
+// outputValues completes --output of the commands printing reports, bundle takes a file instead
+var outputValues = map[string]func() []string{
+	"output": func() []string { return outputFormats },
+}
+
+// jsonOutput tells whether --output json was given, other formats than text and json are usage errors
+func jsonOutput() (bool, error) {
+	if !contains(outputFormats, *output) {
+		return false, usagef("no such output format: %v, use one of %v", *output, strings.Join(outputFormats, ", "))
+	}
+	return *output == "json", nil
+}
+
+// nodeReport is the state of a node printed by --output json
+type nodeReport struct {
+	ID          string   `json:"id"`
+	IP          string   `json:"ip"`
+	Fqdn        string   `json:"fqdn"`
+	Arch        string   `json:"arch"`
+	Components  []string `json:"components"`
+	Removed     []string `json:"removed"`
+	Connections []string `json:"connections"`
+	Targets     []string `json:"targets"`
+	// Runtime, Pod and Unit are set while the node is started
+	Runtime string `json:"runtime,omitempty"`
+	Pod     string `json:"pod,omitempty"`
+	Unit    string `json:"unit,omitempty"`
+	// Status is only reported by the status command
+	Status       *container.PodStatus `json:"status,omitempty"`
+	Certificates []certificateReport  `json:"certificates"`
+}
+
+// certificateReport is a certificate of a node printed by --output json
+type certificateReport struct {
+	Node        string    `json:"node"`
+	File        string    `json:"file"`
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	NotAfter    time.Time `json:"notAfter"`
+	DaysLeft    int       `json:"daysLeft"`
+	KeyType     string    `json:"keyType"`
+	Fingerprint string    `json:"fingerprint"`
+	CA          bool      `json:"ca"`
+}
+
+// nonNil keeps empty lists as [] instead of null in the json output
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+// newNodeReport collects the state and the certificates of a node
+func newNodeReport(node *project.Node) (*nodeReport, error) {
+	certificates, err := certificateReports(node.ID)
+	if err != nil {
+		return nil, err
+	}
+	a := node.Arch
+	if a == "" {
+		a = arch.Default
+	}
+	return &nodeReport{
+		ID:           node.ID,
+		IP:           node.IP,
+		Fqdn:         node.Fqdn,
+		Arch:         a,
+		Components:   nonNil(node.Components),
+		Removed:      nonNil(node.Removed),
+		Connections:  nonNil(node.Connections),
+		Targets:      nonNil(node.Targets),
+		Runtime:      node.State.Runtime,
+		Pod:          node.State.PodID,
+		Unit:         node.State.SystemdID,
+		Certificates: certificates,
+	}, nil
+}
+
+// certificateReports returns the ca, the issued and the foreign certificates of a node
+func certificateReports(nodeID string) ([]certificateReport, error) {
+	infos, err := nodeCertificates(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	reports := []certificateReport{}
+	for _, info := range infos {
+		reports = append(reports, certificateReport{
+			Node:        nodeID,
+			File:        info.File,
+			Subject:     info.Subject,
+			Issuer:      info.Issuer,
+			NotAfter:    info.NotAfter,
+			DaysLeft:    int(time.Until(info.NotAfter).Hours() / 24),
+			KeyType:     info.KeyType,
+			Fingerprint: info.Fingerprint,
+			CA:          info.IsCA,
+		})
+	}
+	return reports, nil
+}
+
+// printJSON writes v indented to stdout
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// listNodes prints all nodes of the project
+func listNodes(myProject *project.Project) error {
+	asJSON, err := jsonOutput()
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		reports := []*nodeReport{}
+		for _, id := range myProject.IDs() {
+			report, err := newNodeReport(myProject.Nodes[id])
+			if err != nil {
+				return err
+			}
+			reports = append(reports, report)
+		}
+		return printJSON(reports)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tIP\tFQDN\tARCH\tCOMPONENTS\tPOD")
+	for _, id := range myProject.IDs() {
+		node := myProject.Nodes[id]
+		a := node.Arch
+		if a == "" {
+			a = arch.Default
+		}
+		pod := node.State.PodID
+		if pod == "" {
+			pod = "-"
+		}
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", node.ID, node.IP, node.Fqdn, a, strings.Join(node.Components, ","), pod)
+	}
+	return w.Flush()
+}
+
+// listComponents prints the components of a node, or the whole node with --output json
+func listComponents(node *project.Node) error {
+	asJSON, err := jsonOutput()
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		report, err := newNodeReport(node)
+		if err != nil {
+			return err
+		}
+		return printJSON(report)
+	}
+	for _, component := range node.Components {
+		fmt.Println(component)
+	}
+	return nil
+}
+
+// statusReport prints the state of the pods of nodes as json, a single node is printed as object
+func statusReport(myProject *project.Project, nodeIDs []string, single bool) error {
+	reports := []*nodeReport{}
+	for _, id := range nodeIDs {
+		node := loadNode(myProject, id)
+		report, err := newNodeReport(node)
+		if err != nil {
+			return err
+		}
+		if report.Status, err = nodeRuntime(myProject, node).PodStatus(node); err != nil {
+			return fmt.Errorf("status of %v: %w", id, err)
+		}
+		reports = append(reports, report)
+	}
+	if single {
+		return printJSON(reports[0])
+	}
+	return printJSON(reports)
+}
@@ -1,34 +1,23 @@
 package setup
 
 import (
-	"log"
-	"os"
-	"os/exec"
+	"fmt"
+
+	"github.com/webvariants/susi-dev/executor"
 )
 
-func runScript(script string) {
-	cmd := exec.Command("/bin/bash", "-c", script)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
-	if err != nil {
-		log.Println("Error: ", err)
-	}
+func runScript(script string) error {
+	return executor.Run(executor.Script(script))
 }
 
-func runScriptWithSudo(script string) {
-	cmd := exec.Command("sudo", "/bin/bash", "-c", script)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
-	if err != nil {
-		log.Println("Error: ", err)
-	}
+func runScriptWithSudo(script string) error {
+	return executor.Run(executor.SudoScript(script))
 }
 
 // InstallDependencies installs rkt, acbuild and docker2aci
-func InstallDependencies() {
+func InstallDependencies() error {
 	script := `
+    set -e
     if ! test -f /usr/local/bin/rkt; then
       wget -O /opt/rkt-v1.3.0.tar.gz https://github.com/coreos/rkt/releases/download/v1.3.0/rkt-v1.3.0.tar.gz
       pushd /opt
@@ -51,6 +40,8 @@ func InstallDependencies() {
 			popd
 		fi
   `
-	runScriptWithSudo(script)
-
+	if err := runScriptWithSudo(script); err != nil {
+		return fmt.Errorf("installing the container tools: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,178 @@
+// Package sign creates detached, ascii armored OpenPGP signatures of images
+package sign
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/webvariants/susi-dev/executor"
+	"golang.org/x/crypto/openpgp"
+)
+
+// PassphraseEnv holds the passphrase of the signing key if no passphrase file is given
+const PassphraseEnv = "GPG_PASS"
+
+// Signers lists the names accepted in Config.Signer
+var Signers = []string{"gpg", "openpgp"}
+
+// Config selects how images are signed
+type Config struct {
+	// Signer is gpg (default) or openpgp, which signs without the gpg binary
+	Signer string
+	// KeyID selects the signing key, empty uses the default key of gpg or the first key of SecretKey
+	KeyID string
+	// PassphraseFile contains the passphrase of the key. Without it the passphrase is taken from $GPG_PASS,
+	// if that is empty too gpg asks gpg-agent.
+	PassphraseFile string
+	// SecretKey is the armored secret key file used by the openpgp signer
+	SecretKey string
+}
+
+// Signer writes the signature of file to file.asc
+type Signer interface {
+	Sign(file string) error
+}
+
+// New returns the signer selected by config
+func New(config Config) (Signer, error) {
+	switch config.Signer {
+	case "", "gpg":
+		return &gpgSigner{config}, nil
+	case "openpgp":
+		if config.SecretKey == "" {
+			return nil, fmt.Errorf("the openpgp signer needs a secret key file")
+		}
+		return &openpgpSigner{config: config}, nil
+	}
+	return nil, fmt.Errorf("no such signer: %v (use one of %v)", config.Signer, strings.Join(Signers, ", "))
+}
+
+// Missing signs file if it has no signature yet, a nil signer does nothing
+func Missing(signer Signer, file string) error {
+	if signer == nil {
+		return nil
+	}
+	if _, err := os.Stat(file + ".asc"); err == nil {
+		return nil
+	}
+	fmt.Printf("Signing %v...\n", file)
+	return signer.Sign(file)
+}
+
+// passphrase returns the passphrase from the passphrase file or the environment, nil if there is none
+func (config Config) passphrase() ([]byte, error) {
+	if config.PassphraseFile != "" {
+		data, err := ioutil.ReadFile(config.PassphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(data, "\r\n"), nil
+	}
+	if pass := os.Getenv(PassphraseEnv); pass != "" {
+		return []byte(pass), nil
+	}
+	return nil, nil
+}
+
+// gpgSigner runs gpg, the passphrase is passed on stdin and never on the command line
+type gpgSigner struct {
+	config Config
+}
+
+func (s *gpgSigner) Sign(file string) error {
+	args := []string{"--batch", "--yes"}
+	if s.config.KeyID != "" {
+		args = append(args, "--local-user", s.config.KeyID)
+	}
+	pass, err := s.config.passphrase()
+	if err != nil {
+		return err
+	}
+	if pass != nil {
+		args = append(args, "--pinentry-mode", "loopback", "--passphrase-fd", "0")
+	}
+	args = append(args, "--detach-sign", "--armor", "--output", file+".asc", file)
+	cmd := executor.Program(append([]string{"gpg"}, args...)...)
+	if pass != nil {
+		cmd.Stdin = bytes.NewReader(append(pass, '\n'))
+	}
+	return executor.Run(cmd)
+}
+
+// openpgpSigner signs with a secret key file without the gpg binary
+type openpgpSigner struct {
+	config Config
+	entity *openpgp.Entity
+}
+
+// key loads and decrypts the signing key
+func (s *openpgpSigner) key() (*openpgp.Entity, error) {
+	if s.entity != nil {
+		return s.entity, nil
+	}
+	in, err := os.Open(s.config.SecretKey)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	keyring, err := openpgp.ReadArmoredKeyRing(in)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", s.config.SecretKey, err)
+	}
+	id := strings.ToUpper(strings.TrimPrefix(s.config.KeyID, "0x"))
+	for _, entity := range keyring {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if id != "" && !strings.HasSuffix(fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint), id) {
+			continue
+		}
+		if entity.PrivateKey.Encrypted {
+			pass, err := s.config.passphrase()
+			if err != nil {
+				return nil, err
+			}
+			if pass == nil {
+				return nil, fmt.Errorf("%v is encrypted, set $%v or use a passphrase file", s.config.SecretKey, PassphraseEnv)
+			}
+			if err := entity.PrivateKey.Decrypt(pass); err != nil {
+				return nil, err
+			}
+		}
+		s.entity = entity
+		return entity, nil
+	}
+	if id != "" {
+		return nil, fmt.Errorf("%v contains no secret key %v", s.config.SecretKey, s.config.KeyID)
+	}
+	return nil, fmt.Errorf("%v contains no secret key", s.config.SecretKey)
+}
+
+func (s *openpgpSigner) Sign(file string) error {
+	if executor.IsDryRun() {
+		fmt.Printf("# sign %v with the key in %v\n", file, s.config.SecretKey)
+		return nil
+	}
+	entity, err := s.key()
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(file + ".asc")
+	if err != nil {
+		return err
+	}
+	if err := openpgp.ArmoredDetachSign(out, entity, in, nil); err != nil {
+		out.Close()
+		os.Remove(file + ".asc")
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,80 @@
+package sign
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/webvariants/susi-dev/executor"
+)
+
+const passphrase = "correct horse battery staple"
+
+func TestGPGPassphraseOnStdin(t *testing.T) {
+	recorder := &executor.Recorder{}
+	previous := executor.Default
+	executor.Default = recorder
+	defer func() { executor.Default = previous }()
+	t.Setenv(PassphraseEnv, passphrase)
+
+	signer, err := New(Config{KeyID: "0x1234"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.Sign("image.aci"); err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.Commands) != 1 {
+		t.Fatalf("recorded %v", recorder.Scripts())
+	}
+	want := "gpg --batch --yes --local-user 0x1234 --pinentry-mode loopback --passphrase-fd 0 --detach-sign --armor --output image.aci.asc image.aci"
+	if script := recorder.Scripts()[0]; script != want {
+		t.Errorf("recorded %v, want %v", script, want)
+	}
+	stdin, err := ioutil.ReadAll(recorder.Commands[0].Stdin)
+	if err != nil || string(stdin) != passphrase+"\n" {
+		t.Errorf("gpg gets %q on stdin, %v, want the passphrase", stdin, err)
+	}
+}
+
+func TestGPGDryRunHidesPassphrase(t *testing.T) {
+	var out bytes.Buffer
+	previous := executor.Default
+	executor.Default = &executor.DryRun{Out: &out}
+	defer func() { executor.Default = previous }()
+	t.Setenv(PassphraseEnv, passphrase)
+
+	signer, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.Sign("image.aci"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), passphrase) {
+		t.Errorf("the dry run printed the passphrase:\n%v", out.String())
+	}
+	if !strings.Contains(out.String(), "--passphrase-fd 0") {
+		t.Errorf("the dry run does not show the gpg command:\n%v", out.String())
+	}
+}
+
+func TestGPGWithoutPassphrase(t *testing.T) {
+	recorder := &executor.Recorder{}
+	previous := executor.Default
+	executor.Default = recorder
+	defer func() { executor.Default = previous }()
+	t.Setenv(PassphraseEnv, "")
+
+	signer, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.Sign("image.aci"); err != nil {
+		t.Fatal(err)
+	}
+	if script := recorder.Scripts()[0]; strings.Contains(script, "passphrase") || recorder.Commands[0].Stdin != nil {
+		t.Errorf("without passphrase gpg asks gpg-agent, recorded %v", script)
+	}
+}
@@ -0,0 +1,144 @@
+package sign
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	pgperrors "golang.org/x/crypto/openpgp/errors"
+)
+
+// TrustDir is the trust store of a project, it holds the public keys images have to be signed with
+const TrustDir = "trust"
+
+// ErrUnsigned is returned for images without signature
+var ErrUnsigned = errors.New("image is not signed")
+
+// readKeys reads armored or binary OpenPGP keys
+func readKeys(data []byte) (openpgp.EntityList, error) {
+	if block, err := armor.Decode(bytes.NewReader(data)); err == nil && block.Type == openpgp.PublicKeyType {
+		return openpgp.ReadKeyRing(block.Body)
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(data))
+}
+
+// LoadTrust reads all public keys of a trust store
+func LoadTrust(dir string) (openpgp.EntityList, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.asc"))
+	if err != nil {
+		return nil, err
+	}
+	var keyring openpgp.EntityList
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := readKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", file, err)
+		}
+		keyring = append(keyring, keys...)
+	}
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("there are no trusted keys in %v/, add your public key with susi-dev trust add $file", dir)
+	}
+	return keyring, nil
+}
+
+// Trust adds the public keys of file to a trust store and returns their fingerprints
+func Trust(dir, file string) ([]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := readKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	var fingerprints []string
+	for _, key := range keys {
+		fingerprint := fmt.Sprintf("%X", key.PrimaryKey.Fingerprint)
+		out, err := os.Create(filepath.Join(dir, fingerprint+".asc"))
+		if err != nil {
+			return nil, err
+		}
+		w, err := armor.Encode(out, openpgp.PublicKeyType, nil)
+		if err == nil {
+			err = key.Serialize(w)
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, err
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	return fingerprints, nil
+}
+
+// Key is a trusted key
+type Key struct {
+	Fingerprint string
+	Identities  []string
+}
+
+// Keys lists the keys of a trust store
+func Keys(dir string) ([]Key, error) {
+	keyring, err := LoadTrust(dir)
+	if err != nil {
+		return nil, err
+	}
+	var keys []Key
+	for _, entity := range keyring {
+		key := Key{Fingerprint: fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)}
+		for name := range entity.Identities {
+			key.Identities = append(key.Identities, name)
+		}
+		sort.Strings(key.Identities)
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Verify checks that file.asc is a valid signature of file by a trusted key.
+// Images which changed after signing fail the check.
+func Verify(keyring openpgp.EntityList, file string) error {
+	signature, err := os.Open(file + ".asc")
+	if os.IsNotExist(err) {
+		return ErrUnsigned
+	}
+	if err != nil {
+		return err
+	}
+	defer signature.Close()
+	signed, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer signed.Close()
+	signer, err := openpgp.CheckArmoredDetachedSignature(keyring, signed, signature)
+	if err != nil {
+		if err == pgperrors.ErrUnknownIssuer {
+			return fmt.Errorf("image is signed by an untrusted key")
+		}
+		return fmt.Errorf("invalid signature, the image changed after signing or the signature is broken: %v", err)
+	}
+	if signer == nil {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
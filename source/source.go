@@ -1,144 +1,246 @@
 package source
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"io/ioutil"
 	"os"
-	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/webvariants/susi-dev/arch"
+	"github.com/webvariants/susi-dev/executor"
+	"github.com/webvariants/susi-dev/sign"
 )
 
-func runScript(script string) {
-	cmd := exec.Command("/bin/bash", "-c", script)
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
-	if err != nil {
-		log.Println("Error: ", err)
-	}
+func runScript(script string) error {
+	return executor.Run(executor.Script(script))
 }
 
-func runScriptWithSudo(script string) {
-	cmd := exec.Command("sudo", "/bin/bash", "-c", script)
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
-	if err != nil {
-		log.Println("Error: ", err)
-	}
+func runScriptWithSudo(script string) error {
+	return executor.Run(executor.SudoScript(script))
 }
 
 // Clone clones the susi source into .susi-src
 func Clone() error {
 	script := `
-    if ! test -d .susi-src; then
-      git clone --recursive https://github.com/webvariants/susi.git .susi-src
+    if test -d .susi-src; then
+      echo ".susi-src exists already"
       exit 0
     fi
-    exit 1
+    git clone --recursive https://github.com/webvariants/susi.git .susi-src
   `
 	fmt.Println("cloning susi...")
-	runScript(script)
+	if err := runScript(script); err != nil {
+		return fmt.Errorf("cloning susi: %w", err)
+	}
 	return nil
 }
 
 // Checkout checks out a branch on the susi repo
 func Checkout(branch string) error {
 	script := fmt.Sprintf(`
+		set -e
 		pushd .susi-src
 	  git checkout %v
   `, branch)
 	fmt.Printf("checkout branch %v...\n", branch)
-	runScript(script)
+	if err := runScript(script); err != nil {
+		return fmt.Errorf("checking out %v: %w", branch, err)
+	}
 	return nil
 }
 
-//Build builds susi for alpine
-func Build(gpgpass string) {
-	buildAlpineBuilder(gpgpass)
+// alpineLibraries are the packages susi links against on alpine
+const alpineLibraries = "openssl-dev linux-headers boost-dev mosquitto-dev leveldb-dev@testing"
+
+// debianLibraries are the packages susi links against on debian
+var debianLibraries = []string{"libssl-dev", "libboost-all-dev", "libmosquitto-dev", "libmosquittopp-dev", "libleveldb-dev"}
+
+// gowebstack builds susi-gowebstack into /out/bin, cross compiled for a
+func gowebstack(a *arch.Arch) string {
+	if !a.Cross() {
+		return "GOPATH=/out go get github.com/webvariants/susi-gowebstack"
+	}
+	return fmt.Sprintf("GOPATH=/out go get -d github.com/webvariants/susi-gowebstack && GOPATH=/out %v go build -o /out/bin/susi-gowebstack github.com/webvariants/susi-gowebstack", a.GoEnv())
+}
+
+// builderImage is the rkt image which builds susi for system and a
+func builderImage(system string, a *arch.Arch) string {
+	return fmt.Sprintf("/var/lib/susi-dev/containers/susi-builder-%v-%v-latest-linux-amd64.aci", system, a.Name)
+}
+
+// Dir is the directory of the build artifacts for system and a
+func Dir(system string, a *arch.Arch) string {
+	return fmt.Sprintf(".build/%v-%v", system, a.Name)
+}
+
+// Build builds susi for alpine, other architectures than amd64 are cross compiled
+func Build(a *arch.Arch, signer sign.Signer) error {
+	builder := builderImage("alpine", a)
+	if err := buildAlpineBuilder(a, builder, signer); err != nil {
+		return err
+	}
 	script := fmt.Sprintf(`
-  mkdir -p .build/alpine
+  set -e
+  mkdir -p %v
   sudo rkt run \
 	--trust-keys-from-https \
   --volume susi,kind=host,source=$(pwd)/.susi-src \
-  --volume out,kind=host,source=$(pwd)/.build/alpine \
-  /var/lib/susi-dev/containers/susi-builder-alpine-latest-linux-amd64.aci
-  `)
-	fmt.Println("Running alpine build...")
-	runScriptWithSudo(script)
+  --volume out,kind=host,source=$(pwd)/%v \
+  %v
+  `, Dir("alpine", a), Dir("alpine", a), builder)
+	fmt.Printf("Running alpine %v build...\n", a.Name)
+	if err := runScriptWithSudo(script); err != nil {
+		return fmt.Errorf("alpine %v build: %w", a.Name, err)
+	}
+	return nil
 }
 
-//Package produces a debian package
-func Package(debianVersion, gpgpass string) {
-	buildDebianBuilder(debianVersion, gpgpass)
+// Package produces a debian package
+func Package(debianVersion string, a *arch.Arch, signer sign.Signer) error {
+	builder := builderImage("debian-"+debianVersion, a)
+	if err := buildDebianBuilder(debianVersion, a, builder, signer); err != nil {
+		return err
+	}
+	dir := Dir("debian-"+debianVersion, a)
 	script := fmt.Sprintf(`
-	mkdir -p .build/debian-%v
+	set -e
+	mkdir -p %v
 	sudo rkt run \
 		--trust-keys-from-https \
 		--volume susi,kind=host,source=$(pwd)/.susi-src \
-		--volume out,kind=host,source=$(pwd)/.build/debian-%v \
-		/var/lib/susi-dev/containers/susi-builder-debian-%v-latest-linux-amd64.aci
-	cp .build/debian-%v/*.deb ./susi-debian-%v.deb
-	`, debianVersion, debianVersion, debianVersion, debianVersion, debianVersion)
-	fmt.Printf("Running debian %v build...\n", debianVersion)
-	runScriptWithSudo(script)
+		--volume out,kind=host,source=$(pwd)/%v \
+		%v
+	cp %v/*.deb ./susi-debian-%v-%v.deb
+	`, dir, dir, builder, dir, debianVersion, a.Name)
+	fmt.Printf("Running debian %v %v build...\n", debianVersion, a.Name)
+	if err := runScriptWithSudo(script); err != nil {
+		return fmt.Errorf("debian %v %v build: %w", debianVersion, a.Name, err)
+	}
+	return nil
 }
 
-//BuildNative use the host tools to compile susi
-func BuildNative() {
-	script := `
-		mkdir -p .build/native
-		cd .build/native
+// BuildNative use the host tools to compile susi
+func BuildNative(a *arch.Arch) error {
+	if a.GOARCH != runtime.GOARCH {
+		return fmt.Errorf("native builds are only possible for the host architecture %v", runtime.GOARCH)
+	}
+	script := fmt.Sprintf(`
+		set -e
+		mkdir -p %v
+		cd %v
 		cmake ../../.susi-src
 		make -j8 package
-		cp *.deb ../../susi-native-build.deb
-	`
+		cp *.deb ../../susi-native-build-%v.deb
+	`, Dir("native", a), Dir("native", a), a.Name)
 	fmt.Printf("Running native build...\n")
-	runScriptWithSudo(script)
+	if err := runScriptWithSudo(script); err != nil {
+		return fmt.Errorf("native build: %w", err)
+	}
+	return nil
 }
 
-func buildAlpineBuilder(gpgpass string) {
-	if _, err := os.Stat("/var/lib/susi-dev/containers/susi-builder-alpine-latest-linux-amd64.aci"); err != nil && gpgpass == "" {
-		log.Fatal("please specify --gpgpass")
+// writeToolchain writes the cmake toolchain file of a cross builder to a temporary file
+func writeToolchain(a *arch.Arch, triplet, sysroot string) (string, error) {
+	file, err := ioutil.TempFile("", "susi-dev-toolchain")
+	if err != nil {
+		return "", err
 	}
-	script := `
-	if ! test -f /var/lib/susi-dev/containers/susi-builder-alpine-latest-linux-amd64.aci; then
+	defer file.Close()
+	if _, err := file.WriteString(a.Toolchain(triplet, sysroot)); err != nil {
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// signBuilder signs a builder image, rkt only runs signed images
+func signBuilder(builder string, signer sign.Signer) error {
+	if err := sign.Missing(signer, builder); err != nil {
+		return fmt.Errorf("signing %v: %w", builder, err)
+	}
+	return nil
+}
+
+// checkSigner fails if a missing builder image would stay unsigned
+func checkSigner(builder string, signer sign.Signer) error {
+	if _, err := os.Stat(builder); err != nil && signer == nil {
+		return errors.New("the builder image has to be signed, do not use --no-sign")
+	}
+	return nil
+}
+
+func buildAlpineBuilder(a *arch.Arch, builder string, signer sign.Signer) error {
+	if err := checkSigner(builder, signer); err != nil {
+		return err
+	}
+	libraries := alpineLibraries
+	cross := ""
+	exec := fmt.Sprintf("cd /out && cmake /susi && make -j8 && %v", gowebstack(a))
+	if a.Cross() {
+		// the libraries of the target are installed into the sysroot instead
+		libraries = ""
+		toolchain, err := writeToolchain(a, a.Musl, "/sysroot")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(toolchain)
+		cross = fmt.Sprintf(`acbuild --debug run -- /bin/sh -c "curl -sSL https://musl.cc/%v-cross.tgz | tar xz -C /opt"
+		acbuild --debug run -- mkdir -p /sysroot/etc/apk
+		acbuild --debug run -- cp /etc/apk/repositories /sysroot/etc/apk/repositories
+		acbuild --debug run -- apk --root /sysroot --arch %v --initdb --allow-untrusted --update-cache add musl-dev %v
+		acbuild --debug copy %v /toolchain.cmake
+		acbuild --debug environment add PATH /opt/%v-cross/bin:/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin`,
+			a.Musl, a.Alpine, alpineLibraries, toolchain, a.Musl)
+		exec = fmt.Sprintf("cd /out && cmake -DCMAKE_TOOLCHAIN_FILE=/toolchain.cmake /susi && make -j8 && %v", gowebstack(a))
+	}
+	script := fmt.Sprintf(`
+	if ! test -f %v; then
 		set -e
 		mkdir -p /var/lib/susi-dev/containers
 		chmod 777 /var/lib/susi-dev/containers
 		acbuild --debug begin
 		trap "{ export EXT=$?; acbuild --debug end && exit $EXT; }" EXIT
-		acbuild --debug set-name susi.io/alpine-builder
+		acbuild --debug set-name susi.io/alpine-%v-builder
 		acbuild --debug dep add quay.io/coreos/alpine-sh
 		acbuild --debug run -- mkdir -p /etc/apk
-		acbuild --debug run -- /bin/sh -c "echo -en 'http://dl-4.alpinelinux.org/alpine/v3.3/main\n@community http://dl-4.alpinelinux.org/alpine/v3.3/community\n@testing http://dl-4.alpinelinux.org/alpine/edge/testing\n' > /etc/apk/repositories"
+		acbuild --debug run -- /bin/sh -c "echo -en 'http://dl-4.alpinelinux.org/alpine/v3.5/main\n@community http://dl-4.alpinelinux.org/alpine/v3.5/community\n@testing http://dl-4.alpinelinux.org/alpine/edge/testing\n' > /etc/apk/repositories"
 		acbuild --debug run -- apk update
-		acbuild --debug run -- apk add gcc g++ make cmake git perl python py-lxml openssl-dev linux-headers boost-dev mosquitto-dev leveldb-dev@testing go@community
+		acbuild --debug run -- apk add gcc g++ make cmake git perl python py-lxml curl go@community %v
+		%v
 		acbuild --debug mount add susi /susi
 		acbuild --debug mount add out /out
-		acbuild --debug set-exec -- /bin/sh -c "cd /out && cmake /susi && make -j8 && GOPATH=/out go get github.com/webvariants/susi-gowebstack"
-		acbuild --debug write --overwrite /var/lib/susi-dev/containers/susi-builder-alpine-latest-linux-amd64.aci
+		acbuild --debug set-exec -- /bin/sh -c "%v"
+		acbuild --debug write --overwrite %v
 	fi
-	`
-	fmt.Println("Preparing alpine build container...")
-	runScriptWithSudo(script)
-	if gpgpass != "" {
-		fmt.Println("Signing alpine build container...")
-		signScript := fmt.Sprintf(`
-			if ! test -f /var/lib/susi-dev/containers/susi-builder-alpine-latest-linux-amd64.aci.asc; then
-				gpg --batch --passphrase %v --sign --detach-sign --armor /var/lib/susi-dev/containers/susi-builder-alpine-latest-linux-amd64.aci
-			fi
-			`, gpgpass)
-		runScript(signScript)
+	`, builder, a.Name, libraries, cross, exec, builder)
+	fmt.Printf("Preparing alpine %v build container...\n", a.Name)
+	if err := runScriptWithSudo(script); err != nil {
+		return fmt.Errorf("preparing the alpine %v build container: %w", a.Name, err)
 	}
+	return signBuilder(builder, signer)
 }
 
-func buildDebianBuilder(version, gpgpass string) {
-	container := fmt.Sprintf("/var/lib/susi-dev/containers/susi-builder-debian-%v-latest-linux-amd64.aci", version)
-	if _, err := os.Stat(container); err != nil && gpgpass == "" {
-		log.Fatal("please specify --gpgpass")
+func buildDebianBuilder(version string, a *arch.Arch, builder string, signer sign.Signer) error {
+	if err := checkSigner(builder, signer); err != nil {
+		return err
+	}
+	packages := "cmake make gcc g++ git golang " + strings.Join(debianLibraries, " ")
+	cross := ""
+	exec := fmt.Sprintf("cd /out && cmake /susi && make -j8 package && %v", gowebstack(a))
+	if a.Cross() {
+		toolchain, err := writeToolchain(a, a.GNU, "")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(toolchain)
+		packages = fmt.Sprintf("cmake make git golang crossbuild-essential-%v %v:%v", a.Debian, strings.Join(debianLibraries, ":"+a.Debian+" "), a.Debian)
+		cross = fmt.Sprintf(`acbuild --debug run -- dpkg --add-architecture %v
+    acbuild --debug copy %v /toolchain.cmake`, a.Debian, toolchain)
+		exec = fmt.Sprintf("cd /out && cmake -DCMAKE_TOOLCHAIN_FILE=/toolchain.cmake -DCPACK_DEBIAN_PACKAGE_ARCHITECTURE=%v /susi && make -j8 package && %v", a.Debian, gowebstack(a))
 	}
 	script := fmt.Sprintf(`
-  if ! test -f /var/lib/susi-dev/containers/susi-builder-debian-%v-latest-linux-amd64.aci; then
+  if ! test -f %v; then
     set -e
 		mkdir -p /var/lib/susi-dev/containers
 		chmod 777 /var/lib/susi-dev/containers
@@ -148,30 +250,25 @@ func buildDebianBuilder(version, gpgpass string) {
 		fi
     acbuild begin /var/lib/susi-dev/containers/debian-%v.aci
     trap "{ export EXT=$?; acbuild --debug end && exit $EXT; }" EXIT
-    acbuild set-name susi.io/debian-%v-builder
+    acbuild set-name susi.io/debian-%v-%v-builder
+    %v
     acbuild --debug run -- apt-get --yes update
-    acbuild --debug run -- apt-get --yes install cmake make gcc g++ git libssl-dev libboost-all-dev libmosquitto-dev libmosquittopp-dev libleveldb-dev golang
+    acbuild --debug run -- apt-get --yes install %v
 		acbuild --debug run -- apt-get clean
     acbuild mount add susi /susi
     acbuild mount add out /out
-    acbuild set-exec -- /bin/sh -c "cd /out && cmake /susi && make -j8 package && GOPATH=/out go get github.com/webvariants/susi-gowebstack"
-    acbuild --debug write --overwrite /var/lib/susi-dev/containers/susi-builder-debian-%v-latest-linux-amd64.aci
+    acbuild set-exec -- /bin/sh -c "%v"
+    acbuild --debug write --overwrite %v
   fi
 
   if ! test -d .susi-src; then
     git clone --recursive https://github.com/webvariants/susi.git .susi-src
   fi
-  `, version, version, version, version, version, version, version, version)
-
-	fmt.Printf("Preparing debian %v build container...\n", version)
-	runScriptWithSudo(script)
-	if gpgpass != "" {
-		fmt.Printf("Signing debian %v build container...\n", version)
-		signScript := fmt.Sprintf(`
-			if ! test -f /var/lib/susi-dev/containers/susi-builder-debian-%v-latest-linux-amd64.aci.asc; then
-				gpg --batch --passphrase %v --sign --detach-sign --armor /var/lib/susi-dev/containers/susi-builder-debian-%v-latest-linux-amd64.aci
-			fi
-			`, version, gpgpass, version)
-		runScript(signScript)
+  `, builder, version, version, version, version, version, version, a.Name, cross, packages, exec, builder)
+
+	fmt.Printf("Preparing debian %v %v build container...\n", version, a.Name)
+	if err := runScriptWithSudo(script); err != nil {
+		return fmt.Errorf("preparing the debian %v %v build container: %w", version, a.Name, err)
 	}
+	return signBuilder(builder, signer)
 }
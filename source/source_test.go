@@ -0,0 +1,168 @@
+package source
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/webvariants/susi-dev/arch"
+	"github.com/webvariants/susi-dev/executor"
+)
+
+// record replaces the default executor with a recorder for the test
+func record(t *testing.T) *executor.Recorder {
+	recorder := &executor.Recorder{}
+	previous := executor.Default
+	executor.Default = recorder
+	t.Cleanup(func() { executor.Default = previous })
+	return recorder
+}
+
+// signer remembers the files it signed
+type signer struct {
+	files []string
+}
+
+func (s *signer) Sign(file string) error {
+	s.files = append(s.files, file)
+	return nil
+}
+
+func archs(t *testing.T) []*arch.Arch {
+	var archs []*arch.Arch
+	for _, name := range arch.Names() {
+		a, err := arch.Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		archs = append(archs, a)
+	}
+	return archs
+}
+
+// contains fails the test for every part missing in script
+func contains(t *testing.T, script string, parts ...string) {
+	t.Helper()
+	for _, part := range parts {
+		if !strings.Contains(script, part) {
+			t.Errorf("missing %q in:\n%v", part, script)
+		}
+	}
+}
+
+// scripts returns the builder and the build script, both run as root
+func scripts(t *testing.T, recorder *executor.Recorder) (string, string) {
+	t.Helper()
+	if len(recorder.Commands) != 2 {
+		t.Fatalf("recorded %v commands, want the builder and the build: %v", len(recorder.Commands), recorder.Scripts())
+	}
+	for _, command := range recorder.Commands {
+		if !command.Sudo {
+			t.Errorf("not run as root: %v", command)
+		}
+	}
+	return recorder.Commands[0].Script, recorder.Commands[1].Script
+}
+
+func TestBuildAlpine(t *testing.T) {
+	for _, a := range archs(t) {
+		t.Run(a.Name, func(t *testing.T) {
+			recorder := record(t)
+			s := &signer{}
+			if err := Build(a, s); err != nil {
+				t.Fatal(err)
+			}
+			builder, build := scripts(t, recorder)
+			image := builderImage("alpine", a)
+			contains(t, builder, "acbuild --debug set-name susi.io/alpine-"+a.Name+"-builder", "acbuild --debug write --overwrite "+image)
+			if a.Cross() {
+				contains(t, builder,
+					"https://musl.cc/"+a.Musl+"-cross.tgz",
+					"apk --root /sysroot --arch "+a.Alpine+" --initdb",
+					"cmake -DCMAKE_TOOLCHAIN_FILE=/toolchain.cmake /susi",
+					a.GoEnv())
+			} else {
+				contains(t, builder, "apk add gcc g++ make cmake git perl python py-lxml curl go@community "+alpineLibraries)
+				if strings.Contains(builder, "toolchain") {
+					t.Errorf("native alpine builder uses a toolchain file:\n%v", builder)
+				}
+			}
+			contains(t, build, "mkdir -p .build/alpine-"+a.Name, "--volume out,kind=host,source=$(pwd)/.build/alpine-"+a.Name, image)
+			if _, err := os.Stat(image + ".asc"); err == nil {
+				return
+			}
+			if len(s.files) != 1 || s.files[0] != image {
+				t.Errorf("signed %v, want the builder %v", s.files, image)
+			}
+		})
+	}
+}
+
+func TestPackageDebian(t *testing.T) {
+	for _, version := range []string{"stable", "testing"} {
+		for _, a := range archs(t) {
+			t.Run(version+"-"+a.Name, func(t *testing.T) {
+				recorder := record(t)
+				if err := Package(version, a, &signer{}); err != nil {
+					t.Fatal(err)
+				}
+				builder, build := scripts(t, recorder)
+				contains(t, builder,
+					"docker2aci docker://debian:"+version,
+					"acbuild set-name susi.io/debian-"+version+"-"+a.Name+"-builder",
+					"acbuild --debug write --overwrite "+builderImage("debian-"+version, a))
+				if a.Cross() {
+					contains(t, builder,
+						"dpkg --add-architecture "+a.Debian,
+						"crossbuild-essential-"+a.Debian,
+						"libssl-dev:"+a.Debian,
+						"-DCPACK_DEBIAN_PACKAGE_ARCHITECTURE="+a.Debian)
+				} else {
+					contains(t, builder, "apt-get --yes install cmake make gcc g++ git golang "+strings.Join(debianLibraries, " "))
+				}
+				dir := ".build/debian-" + version + "-" + a.Name
+				contains(t, build, "--volume out,kind=host,source=$(pwd)/"+dir, "cp "+dir+"/*.deb ./susi-debian-"+version+"-"+a.Name+".deb")
+			})
+		}
+	}
+}
+
+func TestBuildNative(t *testing.T) {
+	for _, a := range archs(t) {
+		t.Run(a.Name, func(t *testing.T) {
+			recorder := record(t)
+			err := BuildNative(a)
+			if a.GOARCH != runtime.GOARCH {
+				if err == nil || len(recorder.Commands) > 0 {
+					t.Errorf("native build for %v on %v: %v, %v", a.Name, runtime.GOARCH, err, recorder.Scripts())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recorder.Commands) != 1 {
+				t.Fatalf("recorded %v", recorder.Scripts())
+			}
+			contains(t, recorder.Commands[0].Script, "cd .build/native-"+a.Name, "cmake ../../.susi-src", "cp *.deb ../../susi-native-build-"+a.Name+".deb")
+		})
+	}
+}
+
+func TestBuilderNeedsSigner(t *testing.T) {
+	recorder := record(t)
+	a, err := arch.Lookup(arch.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(builderImage("alpine", a)); err == nil {
+		t.Skip("the builder image exists already")
+	}
+	if err := Build(a, nil); err == nil {
+		t.Error("built an unsigned builder image")
+	}
+	if len(recorder.Commands) > 0 {
+		t.Errorf("ran %v without signer", recorder.Scripts())
+	}
+}
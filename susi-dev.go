@@ -1,333 +1,670 @@
 package main
 
 import (
-	"flag"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"net"
 	"os"
-	"os/exec"
-	"strconv"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/webvariants/susi-dev/arch"
 	"github.com/webvariants/susi-dev/components"
 	"github.com/webvariants/susi-dev/container"
 	"github.com/webvariants/susi-dev/deploy"
-	"github.com/webvariants/susi-dev/nodes"
+	"github.com/webvariants/susi-dev/dns"
+	"github.com/webvariants/susi-dev/executor"
 	"github.com/webvariants/susi-dev/pki"
-	"github.com/webvariants/susi-dev/setup"
+	"github.com/webvariants/susi-dev/project"
+	"github.com/webvariants/susi-dev/sign"
 	"github.com/webvariants/susi-dev/source"
 )
 
 var (
-	addFlags   = flag.NewFlagSet("add", flag.ContinueOnError)
-	connectTo  *string
-	fqdn       *string
-	buildFlags = flag.NewFlagSet("build", flag.ContinueOnError)
-	targetOS   *string
-	gpgPass    *string
+	connectTo         *string
+	fqdn              *string
+	staticIP          *string
+	dnsListen         *string
+	dnsUpstream       *string
+	fleetCount        *int
+	fleetInventory    *string
+	targetOS          *string
+	targetArch        *string
+	gpgPass           *string
+	signConfig        sign.Config
+	noSign            *bool
+	renewDays         *int
+	deployPlan        *bool
+	bundleOS          *string
+	bundleOut         *string
+	startInsecure     *bool
+	packageFormat     *string
+	packageVersion    *string
+	packageMaintainer *string
+	packageDepends    *string
+	addSettings       settings
+	// topicFlags are the flags of connect which set the topic lists of a cluster connection
+	topicFlags = map[string]string{
+		"forward-consumers":   "forwardConsumers",
+		"forward-processors":  "forwardProcessors",
+		"register-consumers":  "registerConsumers",
+		"register-processors": "registerProcessors",
+	}
 )
 
-func help() {
-	helpText := `usage: susi-dev
-  setup -> install container tools
-  create $node -> bootstrap a new node
-  add $node $component -> setup a component on the given node
-  deploy $node $target -> deploy a node to a target
-  source
-    clone -> clone the source of susi
-    checkout $branch -> checkout a specific branch
-    build --os $OS --gpgpass $pass -> build it for one of alpine, debian-stable, debian-testing or native
-  container
-    build $node --gpgpass $pass -> build containers for a node
-    run $node -> runs the containers for a node
-  pki
-    create $folder -> create a new public key infrastructure
-    add $folder $client -> create and sign a new client certificate
-`
-	fmt.Print(helpText)
-}
-
-func init() {
-	connectTo = addFlags.String("connect-to", "", "connect to this instance")
-	fqdn = addFlags.String("fqdn", "", "address of the instance")
-	targetOS = buildFlags.String("os", "alpine", "for which OS")
-	gpgPass = buildFlags.String("gpgpass", "", "password for signing key")
-}
-
-func start(nodeID string) {
-	myNodes, _ := nodes.Load("nodes.txt")
-	node := myNodes[nodeID]
-	if node.PodID != "" {
-		script := fmt.Sprintf("sudo systemctl stop %v\nsudo rkt rm %v\n", node.SystemdID, node.PodID)
-		cmd := exec.Command("/bin/bash", "-c", script)
-		cmd.Stderr = os.Stderr
-		cmd.Stdout = os.Stdout
-		err := cmd.Run()
-		if err != nil {
-			log.Println("Error: ", err)
+// settings collects repeated --set name=value flags
+type settings []components.Setting
+
+func (s *settings) String() string {
+	return ""
+}
+
+func (s *settings) Set(value string) error {
+	setting, err := components.ParseSetting(value)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, setting)
+	return nil
+}
+
+// exit codes which tell CI what went wrong
+const (
+	// exitFailure is a failed build, deploy or other step
+	exitFailure = 1
+	// exitDrift is returned by deploy --plan if the target differs from the project
+	exitDrift = 2
+	// exitUsage is a wrong command line (EX_USAGE of sysexits.h)
+	exitUsage = 64
+	// exitMissing is a missing dependency like acbuild or rkt (EX_UNAVAILABLE of sysexits.h)
+	exitMissing = 69
+)
+
+// usageError is a wrong command line
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{fmt.Sprintf(format, args...)}
+}
+
+// fail prints err and exits with the matching exit code
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "Error:", err)
+	var usage *usageError
+	switch {
+	case errors.As(err, &usage):
+		fmt.Fprintln(os.Stderr, "see susi-dev help $command")
+		os.Exit(exitUsage)
+	case errors.Is(err, executor.ErrMissing):
+		fmt.Fprintln(os.Stderr, "install the missing tools, susi-dev setup installs the container tools")
+		os.Exit(exitMissing)
+	}
+	os.Exit(exitFailure)
+}
+
+// check stops at the first error
+func check(err error) {
+	if err != nil {
+		fail(err)
+	}
+}
+
+// loadedProject is loaded once, the validation of arguments and the commands share it
+var loadedProject *project.Project
+
+func loadProject() *project.Project {
+	if loadedProject == nil {
+		myProject, err := project.Load()
+		check(err)
+		check(components.LoadDir("components"))
+		loadedProject = myProject
+	}
+	return loadedProject
+}
+
+func loadNode(myProject *project.Project, nodeID string) *project.Node {
+	node, err := myProject.Node(nodeID)
+	check(err)
+	return node
+}
+
+func saveProject(myProject *project.Project) {
+	if executor.IsDryRun() {
+		return
+	}
+	check(myProject.Save())
+}
+
+// planned prints the changes a command would make to the project directory as a comment,
+// it returns true in a dry run and the command has to stop before changing anything
+func planned(format string, args ...interface{}) bool {
+	if !executor.IsDryRun() {
+		return false
+	}
+	fmt.Printf("# "+format+"\n", args...)
+	return true
+}
+
+// podNetwork returns the network of the pods, with DNS they resolve the nodes at the gateway
+func podNetwork(myProject *project.Project) container.Network {
+	network := container.Network{Subnet: myProject.Subnet}
+	if myProject.DNS {
+		gateway, err := myProject.Gateway()
+		check(err)
+		network.DNS = gateway
+	}
+	return network
+}
+
+func projectRuntime(myProject *project.Project) container.Runtime {
+	runtime, err := container.New(myProject.Runtime, podNetwork(myProject))
+	check(err)
+	return runtime
+}
+
+// nodeRuntime returns the runtime a node has been started with
+func nodeRuntime(myProject *project.Project, node *project.Node) container.Runtime {
+	if node.State.Runtime == "" {
+		return projectRuntime(myProject)
+	}
+	runtime, err := container.New(node.State.Runtime, podNetwork(myProject))
+	check(err)
+	return runtime
+}
+
+func start(myProject *project.Project, nodeID string) error {
+	node := loadNode(myProject, nodeID)
+	if err := myProject.CheckIPs(); err != nil {
+		return err
+	}
+	if node.State.PodID != "" {
+		if err := nodeRuntime(myProject, node).Stop(node); err != nil {
+			return fmt.Errorf("stopping %v: %w", nodeID, err)
 		}
+		saveProject(myProject)
 	}
-	uuid := container.Prepare(nodeID)
-	node.PodID = uuid
-	node.SystemdID = container.Run(node.PodID, node.IP)
-	myNodes.Set(node)
-	myNodes.Save("nodes.txt")
-}
-
-func stop(nodeID string) {
-	myNodes, _ := nodes.Load("nodes.txt")
-	node := myNodes[nodeID]
-	script := fmt.Sprintf("sudo systemctl stop %v\nsudo rkt rm %v\n", node.SystemdID, node.PodID)
-	cmd := exec.Command("/bin/bash", "-c", script)
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
+	runtime := projectRuntime(myProject)
+	images, err := container.NodeImages(runtime, node)
 	if err != nil {
-		log.Println("Error: ", err)
+		return err
 	}
+	if !*startInsecure {
+		if err := verifyImages(node, images); err != nil {
+			return err
+		}
+	}
+	if err := runtime.Start(node, images, *startInsecure); err != nil {
+		return fmt.Errorf("starting %v: %w", nodeID, err)
+	}
+	node.State.Runtime = runtime.Name()
+	saveProject(myProject)
+	return nil
 }
 
-func status(nodeID string) {
-	myNodes, _ := nodes.Load("nodes.txt")
-	node := myNodes[nodeID]
-	script := fmt.Sprintf("sudo systemctl status %v", node.SystemdID)
-	cmd := exec.Command("/bin/bash", "-c", script)
-	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
-	err := cmd.Run()
+func stop(myProject *project.Project, nodeID string) error {
+	node := loadNode(myProject, nodeID)
+	err := nodeRuntime(myProject, node).Stop(node)
+	saveProject(myProject)
 	if err != nil {
-		log.Println("Error: ", err)
+		return fmt.Errorf("stopping %v: %w", nodeID, err)
 	}
+	return nil
+}
+
+func status(myProject *project.Project, nodeID string) error {
+	node := loadNode(myProject, nodeID)
+	return nodeRuntime(myProject, node).Status(node)
 }
 
-func build(nodeID string) {
+// build builds the images of a node, --arch changes the architecture of the node
+func build(myProject *project.Project, nodeID string) error {
+	node := loadNode(myProject, nodeID)
+	if *targetArch != "" {
+		node.Arch = *targetArch
+		if node.Arch == arch.Default {
+			node.Arch = ""
+		}
+	}
+	a := lookupArch(node.Arch)
 	switch *targetOS {
 	case "alpine":
 		{
-			for _, component := range components.List(nodeID) {
-				components.Build(nodeID, component, *gpgPass)
+			if err := myProject.CheckIPs(); err != nil {
+				return err
+			}
+			if !planned("write the hosts file of the images to .hosts") {
+				if err := myProject.WriteHosts(".hosts"); err != nil {
+					return err
+				}
+			}
+			runtime := projectRuntime(myProject)
+			signer := newSigner()
+			for _, component := range node.Components {
+				if err := components.Build(runtime, nodeID, component, a, signer); err != nil {
+					return err
+				}
 			}
 		}
 	default:
 		{
-			log.Fatal("no such target os")
+			return usagef("no such target os: %v, images are built on alpine", *targetOS)
+		}
+	}
+	return nil
+}
+
+// bundle writes an installation archive of a node with the susi binaries its components need
+func bundle(node *project.Node) error {
+	a := lookupArch(node.Arch)
+	release, err := deploy.Collect(node.ID)
+	if err != nil {
+		return err
+	}
+	var binaries []string
+	for _, component := range node.Components {
+		definition, err := components.Lookup(component)
+		if err != nil {
+			return err
+		}
+		if definition.Image.Binary != "" {
+			binaries = append(binaries, definition.Image.Binary)
+		}
+	}
+	if err := release.AddBinaries(source.Dir(*bundleOS, a), binaries); err != nil {
+		return err
+	}
+	file := *bundleOut
+	if file == "" {
+		file = fmt.Sprintf("susi-%v%v.tar.gz", node.ID, a.Suffix())
+	}
+	if planned("write %v with %v files of %v", file, len(release.Files), node.ID) {
+		return nil
+	}
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := deploy.Bundle(release, node.Removed, out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %v, unpack it on the target and run susi-%v/install.sh as root\n", file, node.ID)
+	return nil
+}
+
+// packageNode writes a debian package with the configs, keys, assets and units of a node
+func packageNode(node *project.Node) error {
+	if *packageFormat != "deb" {
+		return usagef("no such package format: %v", *packageFormat)
+	}
+	release, err := deploy.Collect(node.ID)
+	if err != nil {
+		return err
+	}
+	deb := &deploy.Deb{
+		Version:    *packageVersion,
+		Maintainer: *packageMaintainer,
+		Depends:    *packageDepends,
+	}
+	if deb.Version == "" {
+		deb.Version = time.Now().UTC().Format("20060102.150405")
+	}
+	file := deb.DebFile(node.ID)
+	if planned("write %v with %v files of %v", file, len(release.Files), node.ID) {
+		return nil
+	}
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := deb.WriteDeb(release, node.Removed, out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %v\n", file)
+	return nil
+}
+
+// verifyImages checks the signatures of the images of a node against the trust store of the project
+func verifyImages(node *project.Node, images []container.NodeImage) error {
+	keyring, err := sign.LoadTrust(sign.TrustDir)
+	if err != nil {
+		return err
+	}
+	var failed []string
+	for _, image := range images {
+		if err := sign.Verify(keyring, image.File); err != nil {
+			failed = append(failed, fmt.Sprintf("%v: %v (%v)", image.Component, err, image.File))
 		}
 	}
+	if len(failed) > 0 {
+		return fmt.Errorf("refusing to start %v, images failed verification:\n  %v\nrebuild them with signing enabled or use --insecure",
+			node.ID, strings.Join(failed, "\n  "))
+	}
+	return nil
+}
+
+// newSigner returns the signer selected by the build flags, nil for --no-sign
+func newSigner() sign.Signer {
+	if *gpgPass != "" {
+		fail(usagef("--gpgpass was removed, the passphrase is taken from gpg-agent, $%v or --passphrase-file", sign.PassphraseEnv))
+	}
+	if *noSign {
+		return nil
+	}
+	signer, err := sign.New(signConfig)
+	check(err)
+	return signer
+}
+
+func lookupArch(name string) *arch.Arch {
+	a, err := arch.Lookup(name)
+	if err != nil {
+		fail(usagef("%v", err))
+	}
+	return a
 }
 
-func create(name string) {
-	pki.Init(name + "/pki")
-	os.Mkdir(name+"/configs", 0755)
-	os.Mkdir(name+"/assets", 0755)
-	os.Mkdir(name+"/foreignKeys", 0755)
-	os.Mkdir(name+"/containers", 0755)
-	myNodes, _ := nodes.Load("nodes.txt")
-	fqdn := *fqdn
+// create bootstraps a node, an empty fqdn is the name and an empty ip the next free one
+func create(myProject *project.Project, name, fqdn, ip string) error {
+	if ip == "" {
+		var err error
+		if ip, err = myProject.AllocateIP(); err != nil {
+			return err
+		}
+	} else if err := myProject.CheckIP(name, ip); err != nil {
+		return usagef("ip of %v: %v", name, err)
+	}
+	if planned("create %v with the IP %v: a pki in %v/pki and the directories configs, assets, foreignKeys and containers", name, ip, name) {
+		return nil
+	}
+	if err := pki.Init(name + "/pki"); err != nil {
+		return err
+	}
+	for _, dir := range []string{"configs", "assets", "foreignKeys", "containers"} {
+		if err := os.Mkdir(name+"/"+dir, 0755); err != nil && !os.IsExist(err) {
+			return err
+		}
+	}
 	if fqdn == "" {
 		fqdn = name
 	}
-	node := nodes.Node{
-		ID:        name,
-		IP:        "172.16.28." + strconv.Itoa(len(myNodes)+2),
-		Fqdn:      fqdn,
-		PodID:     "",
-		SystemdID: "",
+	node := &project.Node{
+		ID:   name,
+		IP:   ip,
+		Fqdn: fqdn,
 	}
-	myNodes.Set(node)
-	myNodes.Save("nodes.txt")
+	myProject.Set(node)
+	saveProject(myProject)
+	return nil
 }
 
-func main() {
-	if len(os.Args) == 1 {
-		help()
-		os.Exit(1)
+// nodeCertificates returns the ca, the issued and the foreign certificates of a node
+func nodeCertificates(nodeID string) ([]*pki.Info, error) {
+	infos, err := pki.List(nodeID + "/pki")
+	if err != nil {
+		return nil, err
 	}
-	switch os.Args[1] {
-	case "setup":
-		{
-			setup.InstallDependencies()
-		}
-	case "create":
-		{
-			nodeID := os.Args[2]
-			addFlags.Parse(os.Args[3:])
-			create(nodeID)
+	foreign, err := filepath.Glob(nodeID + "/foreignKeys/*.crt")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range foreign {
+		info, err := pki.Inspect(file)
+		if err != nil {
+			return nil, err
 		}
-	case "add":
-		{
-			nodeID := os.Args[2]
-			component := os.Args[3]
-			addFlags.Parse(os.Args[4:])
-			myNodes, _ := nodes.Load("nodes.txt")
-			fqdn := myNodes[*connectTo].Fqdn
-			if fqdn == "" {
-				fqdn = *connectTo
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// serveDNS answers the queries of the pods until it is stopped, the project file is read for every query
+func serveDNS(myProject *project.Project) error {
+	gateway, err := myProject.Gateway()
+	if err != nil {
+		return err
+	}
+	listen := *dnsListen
+	if listen == "" {
+		listen = gateway + ":53"
+	}
+	upstream := *dnsUpstream
+	switch upstream {
+	case "":
+		upstream = dns.SystemResolver(gateway)
+	case "none":
+		upstream = ""
+	}
+	if executor.IsDryRun() {
+		fmt.Printf("# answer the dns queries for the nodes on %v, forward the others to %v\n", listen, orNone(strings.Fields(upstream)))
+		return nil
+	}
+	conn, err := net.ListenPacket("udp", listen)
+	if err != nil && *dnsListen == "" {
+		return fmt.Errorf("%w, the gateway address exists once a pod has been started, or select another one with --listen", err)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("answering the dns queries for the nodes on %v, forwarding the others to %v\n", listen, orNone(strings.Fields(upstream)))
+	server := &dns.Server{
+		Names: func() (map[string]string, error) {
+			current, err := project.Load()
+			if err != nil {
+				return nil, err
+			}
+			return current.Names(), nil
+		},
+		Upstream: upstream,
+	}
+	return server.Serve(conn)
+}
+
+func pkiStatus(myProject *project.Project) error {
+	asJSON, err := jsonOutput()
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		reports := []certificateReport{}
+		for _, id := range myProject.IDs() {
+			certificates, err := certificateReports(id)
+			if err != nil {
+				return err
 			}
-			components.Add(nodeID, component, connectTo, &fqdn)
+			reports = append(reports, certificates...)
 		}
-	case "deploy":
-		{
-			nodeID := os.Args[2]
-			target := os.Args[3]
-			deploy.Raw(nodeID, target)
+		return printJSON(reports)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NODE\tCERTIFICATE\tEXPIRES\tDAYS LEFT\tISSUER\tKEY")
+	for _, id := range myProject.IDs() {
+		infos, err := nodeCertificates(id)
+		if err != nil {
+			return err
 		}
-	case "pki":
-		{
-			subcommand := os.Args[2]
-			switch subcommand {
-			case "create":
-				{
-					pkiID := os.Args[3]
-					pki.Init(pkiID)
-				}
-			case "add":
-				{
-					pkiID := os.Args[3]
-					name := os.Args[4]
-					pki.CreateCertificate(pkiID, name)
-				}
-			}
+		for _, info := range infos {
+			daysLeft := int(time.Until(info.NotAfter).Hours() / 24)
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", id, info.File, info.NotAfter.Format("2006-01-02"), daysLeft, info.Issuer, info.KeyType)
 		}
-	case "source":
-		{
-			subcommand := os.Args[2]
-			switch subcommand {
-			case "build":
-				{
-					buildFlags.Parse(os.Args[3:])
-					if _, err := os.Stat(".susi-src"); err != nil {
-						source.Clone()
-					}
-					switch *targetOS {
-					case "alpine":
-						{
-							source.Build(*gpgPass)
-						}
-					case "debian-stable":
-						{
-							source.Package("stable", *gpgPass)
-						}
-					case "debian-testing":
-						{
-							source.Package("testing", *gpgPass)
-						}
-					case "native":
-						{
-							source.BuildNative()
-						}
-					default:
-						{
-							log.Fatal("no such target os")
-						}
-					}
-				}
-			case "checkout":
-				{
-					source.Checkout(os.Args[3])
-				}
-			case "clone":
-				{
-					if _, err := os.Stat(".susi-src"); err != nil {
-						source.Clone()
-					}
-				}
-			}
+	}
+	return w.Flush()
+}
+
+func pkiRenew(myProject *project.Project, days int) error {
+	deadline := time.Now().AddDate(0, 0, days)
+	for _, id := range myProject.IDs() {
+		directory := id + "/pki"
+		infos, err := pki.List(directory)
+		if err != nil {
+			return err
 		}
-	case "build":
-		{
-			if os.Args[2][0] == '-' {
-				buildFlags.Parse(os.Args[2:])
-				myNodes, _ := nodes.Load("nodes.txt")
-				for id := range myNodes {
-					build(id)
-				}
-			} else {
-				nodeID := os.Args[2]
-				buildFlags.Parse(os.Args[3:])
-				build(nodeID)
+		// peers whose foreignKeys hold copies of something renewed here
+		refresh := map[string]bool{}
+		for _, info := range infos {
+			if !info.NotAfter.Before(deadline) {
+				continue
 			}
-		}
-	case "start":
-		{
-			if len(os.Args) < 3 {
-				myNodes, _ := nodes.Load("nodes.txt")
-				for id := range myNodes {
-					start(id)
-				}
-			} else {
-				nodeID := os.Args[2]
-				start(nodeID)
+			if planned("renew %v and refresh the foreignKeys of the peers holding copies of it", info.File) {
+				continue
 			}
-		}
-	case "status":
-		{
-			if len(os.Args) < 3 {
-				myNodes, _ := nodes.Load("nodes.txt")
-				for id := range myNodes {
-					status(id)
+			fmt.Printf("renewing %v...\n", info.File)
+			if info.IsCA {
+				err = pki.RenewCA(directory)
+				for _, peerID := range myProject.IDs() {
+					refresh[peerID] = true
 				}
 			} else {
-				nodeID := os.Args[2]
-				status(nodeID)
+				name := strings.TrimSuffix(filepath.Base(info.File), ".crt")
+				err = pki.Renew(directory, name)
+				refresh[name] = true
 			}
-		}
-	case "stop":
-		{
-			if len(os.Args) < 3 {
-				myNodes, _ := nodes.Load("nodes.txt")
-				for id := range myNodes {
-					stop(id)
-				}
-			} else {
-				nodeID := os.Args[2]
-				stop(nodeID)
+			if err != nil {
+				return fmt.Errorf("renewing %v: %w", info.File, err)
 			}
 		}
-	case "logs":
-		{
-			nodeID := os.Args[2]
-			myNodes, _ := nodes.Load("nodes.txt")
-			node := myNodes[nodeID]
-			additional := ""
-			for i := 3; i < len(os.Args); i++ {
-				additional += os.Args[i] + " "
+		for peerID := range refresh {
+			peer, ok := myProject.Nodes[peerID]
+			if !ok || !contains(peer.Connections, id) {
+				continue
 			}
-			script := fmt.Sprintf("sudo journalctl -M rkt-%v %v", node.PodID, additional)
-			cmd := exec.Command("/bin/bash", "-c", script)
-			cmd.Stderr = os.Stderr
-			cmd.Stdout = os.Stdout
-			err := cmd.Run()
-			if err != nil {
-				log.Println("Error: ", err)
+			if err := components.CopyForeignKeys(peerID, id); err != nil {
+				return err
 			}
 		}
-	case "enter":
-		{
-			nodeID := os.Args[2]
-			myNodes, _ := nodes.Load("nodes.txt")
-			node := myNodes[nodeID]
-			script := fmt.Sprintf("sudo rkt enter --app susi-core %v /bin/sh", node.PodID)
-			cmd := exec.Command("/bin/bash", "-c", script)
-			cmd.Stdin = os.Stdin
-			cmd.Stderr = os.Stderr
-			cmd.Stdout = os.Stdout
-			err := cmd.Run()
-			if err != nil {
-				log.Println("Error: ", err)
+	}
+	return nil
+}
+
+func peerAddress(myProject *project.Project, peerID string) string {
+	if peer, ok := myProject.Nodes[peerID]; ok {
+		return peer.Fqdn
+	}
+	return peerID
+}
+
+// addComponent sets up a component on a node, connectTo is the optional node it connects to
+func addComponent(myProject *project.Project, node *project.Node, component, connectTo string, settings []components.Setting) error {
+	if planned("add %v to %v: issue its certificate, write its config, unit file and assets, it connects to %v", component, node.ID, orNone(strings.Fields(connectTo))) {
+		return nil
+	}
+	address := peerAddress(myProject, connectTo)
+	if err := components.Add(node.ID, component, &connectTo, &address, settings); err != nil {
+		return err
+	}
+	node.AddComponent(component)
+	if connectTo != "" {
+		node.AddConnection(connectTo)
+	}
+	saveProject(myProject)
+	return nil
+}
+
+// printParameters lists the parameters of a component, with node their values in its config
+func printParameters(node, component string) error {
+	definition, err := components.Lookup(component)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if node == "" {
+		fmt.Fprintln(w, "PARAMETER\tTYPE\tDEFAULT\tDESCRIPTION")
+	} else {
+		fmt.Fprintln(w, "PARAMETER\tTYPE\tVALUE\tDESCRIPTION")
+	}
+	for _, parameter := range definition.Parameters {
+		value := parameter.Default
+		if node != "" {
+			if value, err = components.Get(node, component, parameter.Name); err != nil {
+				value = "-"
 			}
 		}
-	case "list":
-		{
-			fmt.Println(components.List(os.Args[2]))
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", parameter.Name, parameter.Type, formatValue(value), parameter.Description)
+	}
+	return w.Flush()
+}
+
+// formatValue prints strings as they are and other values as json
+func formatValue(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func connect(myProject *project.Project, nodeID, peerID string, topics components.Topics) error {
+	node := loadNode(myProject, nodeID)
+	loadNode(myProject, peerID)
+	if planned("connect %v to %v: add susi-cluster if it is missing, issue a certificate for %v in the pki of %v, copy it into %v/foreignKeys and add %v to the cluster config",
+		nodeID, peerID, nodeID, peerID, nodeID, peerID) {
+		return nil
+	}
+	if !contains(node.Components, "susi-cluster") {
+		empty := ""
+		if err := components.Add(nodeID, "susi-cluster", &empty, nil, nil); err != nil {
+			return err
 		}
-	case "--help":
-		{
-			help()
-			os.Exit(0)
+		node.AddComponent("susi-cluster")
+	}
+	if err := components.Connect(nodeID, peerID, peerAddress(myProject, peerID), topics); err != nil {
+		return err
+	}
+	node.AddConnection(peerID)
+	saveProject(myProject)
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
 		}
-	default:
-		{
-			help()
-			os.Exit(1)
+	}
+	return false
+}
+
+// dryRun removes --dry-run from the arguments and prints commands instead of running them if it was given
+func dryRun() {
+	args := os.Args[:1]
+	for _, arg := range os.Args[1:] {
+		if arg == "--dry-run" || arg == "-dry-run" {
+			executor.Default = &executor.DryRun{}
+			continue
 		}
+		args = append(args, arg)
+	}
+	os.Args = args
+}
+
+func main() {
+	dryRun()
+	if len(os.Args) == 1 {
+		root.printUsage(os.Stderr, root.name)
+		os.Exit(exitUsage)
+	}
+	if os.Args[1] == "--help" || os.Args[1] == "-h" {
+		root.printUsage(os.Stdout, root.name)
+		return
 	}
+	check(root.execute(root.name, os.Args[1:]))
 }
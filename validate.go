@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/webvariants/susi-dev/components"
+	"github.com/webvariants/susi-dev/deploy"
+	"github.com/webvariants/susi-dev/project"
+)
+
+// nodeProblems returns the invalid configs of the components of a node and the missing files they refer to
+func nodeProblems(myProject *project.Project, nodeID string) ([]string, error) {
+	node := loadNode(myProject, nodeID)
+	release, err := deploy.Collect(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	installed := map[string]bool{}
+	for _, file := range release.Files {
+		installed[deploy.InstallPath(file.Path)] = true
+	}
+	var found []string
+	for _, component := range node.Components {
+		problems, err := components.Validate(nodeID, component, func(path string) bool { return installed[path] })
+		if err != nil {
+			return nil, fmt.Errorf("validating %v of %v: %w", component, nodeID, err)
+		}
+		found = append(found, problems...)
+	}
+	return found, nil
+}
+
+// validate prints the problems of the given nodes and fails if there are any, build and deploy run it first
+func validate(myProject *project.Project, ids ...string) error {
+	var invalid []string
+	for _, id := range ids {
+		found, err := nodeProblems(myProject, id)
+		if err != nil {
+			return err
+		}
+		for _, problem := range found {
+			fmt.Println(problem)
+		}
+		if len(found) > 0 {
+			invalid = append(invalid, id)
+		}
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("the configs of %v are invalid, fix them and run susi-dev validate", strings.Join(invalid, ", "))
+	}
+	return nil
+}